@@ -0,0 +1,89 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// Bus define minimal transport, which sensor
+// driver use to communicate with device.
+// Connection *i2c.I2C from github.com/d2r2/go-i2c
+// satisfy this interface as is, so it could be
+// passed to any sensor method directly.
+type Bus interface {
+	// WriteBytes send bytes to device.
+	WriteBytes(buf []byte) (int, error)
+	// ReadBytes fill buffer with bytes read from device.
+	ReadBytes(buf []byte) (int, error)
+}
+
+// WriteReadBus is optional extension of Bus,
+// which implement combined write-then-read
+// transaction (with repeated start condition).
+// When bus provide it, driver use it for
+// register and identity reads.
+type WriteReadBus interface {
+	Bus
+	// WriteReadBytes send wbuf to device and
+	// fill rbuf with response in one transaction.
+	WriteReadBytes(wbuf []byte, rbuf []byte) error
+}
+
+// Ensure that go-i2c connection can be used as a Bus.
+var _ Bus = (*i2c.I2C)(nil)
+
+// writeBytes send bytes to device, unless context is done.
+func writeBytes(ctx context.Context, bus Bus, buf []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := bus.WriteBytes(buf)
+	return wrapBusError("write", err)
+}
+
+// readBytes read bytes from device, unless context is done.
+func readBytes(ctx context.Context, bus Bus, buf []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := bus.ReadBytes(buf)
+	return wrapBusError("read", err)
+}
+
+// writeReadBytes send command to device and read response,
+// using combined transaction if bus support it.
+func writeReadBytes(ctx context.Context, bus Bus, cmd []byte, buf []byte) error {
+	if wrb, ok := bus.(WriteReadBus); ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return wrapBusError("write-read", wrb.WriteReadBytes(cmd, buf))
+	}
+	err := writeBytes(ctx, bus, cmd)
+	if err != nil {
+		return err
+	}
+	return readBytes(ctx, bus, buf)
+}
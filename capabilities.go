@@ -0,0 +1,187 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"bytes"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Capability denote sensor feature.
+type Capability uint32
+
+const (
+	CAP_HUMIDITY           Capability = 0x1  // Relative humidity measurement
+	CAP_TEMPERATURE        Capability = 0x2  // Temperature measurement
+	CAP_HEATER             Capability = 0x4  // Integrated heater (on/off)
+	CAP_ANALOG_IN          Capability = 0x8  // Analog (thermistor) input and user register 2
+	CAP_HEATER_LEVEL       Capability = 0x10 // Heater control register (heater level)
+	CAP_TEMP_FROM_PREVIOUS Capability = 0x20 // Temperature from previous RH measurement
+	CAP_FIRMWARE_REVISION  Capability = 0x40 // Firmware revision command
+)
+
+// capabilityNames keep capability names in display order.
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CAP_HUMIDITY, "HUMIDITY"},
+	{CAP_TEMPERATURE, "TEMPERATURE"},
+	{CAP_HEATER, "HEATER"},
+	{CAP_ANALOG_IN, "ANALOG_IN"},
+	{CAP_HEATER_LEVEL, "HEATER_LEVEL"},
+	{CAP_TEMP_FROM_PREVIOUS, "TEMP_FROM_PREVIOUS"},
+	{CAP_FIRMWARE_REVISION, "FIRMWARE_REVISION"},
+}
+
+// Has check, that all capabilities c are present.
+func (v Capability) Has(c Capability) bool {
+	return v&c == c
+}
+
+// Names return list of capability names.
+func (v Capability) Names() []string {
+	names := []string{}
+	for _, item := range capabilityNames {
+		if v&item.cap != 0 {
+			names = append(names, item.name)
+		}
+	}
+	return names
+}
+
+// String define stringer interface.
+func (v Capability) String() string {
+	const divider = " | "
+	var buf bytes.Buffer
+	for _, name := range v.Names() {
+		buf.WriteString(name + divider)
+	}
+	if buf.Len() > 0 {
+		buf.Truncate(buf.Len() - len(divider))
+	}
+	return buf.String()
+}
+
+// GetCapabilities return features provided by sensor type
+// with latest firmware. Engineering samples are limited
+// to basic measurements.
+func GetCapabilities(st SensorType) Capability {
+	const common = CAP_HUMIDITY | CAP_TEMPERATURE
+	const si70xx = common | CAP_HEATER | CAP_HEATER_LEVEL |
+		CAP_TEMP_FROM_PREVIOUS | CAP_FIRMWARE_REVISION
+	switch st {
+	case SI_7013_TYPE:
+		return si70xx | CAP_ANALOG_IN
+	case SI_7006_TYPE, SI_7020_TYPE, SI_7021_TYPE:
+		return si70xx
+	case SI_ENGINEERING_TYPE1, SI_ENGINEERING_TYPE2:
+		return common | CAP_FIRMWARE_REVISION
+	default:
+		return common
+	}
+}
+
+// GetFirmwareCapabilities return features provided by
+// sensor type running firmware revision fv:
+//   - firmware 1.0 and 2.0 differ in behaviour, but not in
+//     command set, so both keep all features of sensor type;
+//   - unknown revision is limited to commands, which all
+//     revisions implement: no heater control register and
+//     no temperature from previous RH measurement;
+//   - engineering samples are limited to basic measurements,
+//     unless they run release firmware 2.0, which add heater
+//     and temperature from previous RH measurement.
+func GetFirmwareCapabilities(st SensorType, fv FirmwareVersion) Capability {
+	caps := GetCapabilities(st)
+	switch st {
+	case SI_ENGINEERING_TYPE1, SI_ENGINEERING_TYPE2:
+		if fv == FIRMWARE_VER_2_0 {
+			caps |= CAP_HEATER | CAP_TEMP_FROM_PREVIOUS
+		}
+		return caps
+	}
+	switch fv {
+	case FIRMWARE_VER_1_0, FIRMWARE_VER_2_0:
+		return caps
+	default:
+		return caps &^ (CAP_HEATER_LEVEL | CAP_TEMP_FROM_PREVIOUS)
+	}
+}
+
+// commandCapability return capability, which
+// chip must have to execute command.
+func commandCapability(cmd []byte) Capability {
+	switch {
+	case bytes.Equal(cmd, CMD_READ_FIRMWARE_REV):
+		return CAP_FIRMWARE_REVISION
+	case bytes.HasPrefix(cmd, CMD_WRITE_HEATER_REG), bytes.Equal(cmd, CMD_READ_HEATER_REG):
+		return CAP_HEATER_LEVEL
+	case bytes.Equal(cmd, CMD_TEMP_FROM_PREVIOUS):
+		return CAP_TEMP_FROM_PREVIOUS
+	case bytes.Equal(cmd, CMD_MEASURE_ANALOG),
+		bytes.HasPrefix(cmd, CMD_WRITE_USER_REG_2), bytes.Equal(cmd, CMD_READ_USER_REG_2),
+		bytes.HasPrefix(cmd, CMD_WRITE_THERMIST_COEF), bytes.HasPrefix(cmd, CMD_READ_THERMIST_COEF):
+		return CAP_ANALOG_IN
+	default:
+		return 0
+	}
+}
+
+// capabilities return features driver allow to use: taken
+// from sensor identity, when it was read, otherwise from chip model.
+func (v *Si7021) capabilities() Capability {
+	if v.deviceInfo != nil {
+		return v.deviceInfo.Capabilities
+	}
+	return v.chip().Capabilities
+}
+
+// GetCapabilities return features driver allow to use. Until sensor
+// identity is read with ReadDeviceInfo, they are defined by chip model
+// only, afterwards sensor type and firmware revision are considered too.
+func (v *Si7021) GetCapabilities() Capability {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.capabilities()
+}
+
+// checkCapability return *UnsupportedError, when
+// sensor do not provide required capabilities.
+func (v *Si7021) checkCapability(feature string, need Capability) error {
+	if v.capabilities().Has(need) {
+		return nil
+	}
+	reason := spew.Sprintf("not implemented by %v", v.chip().Model)
+	if v.deviceInfo != nil && v.chip().SensorTypeInID {
+		reason = spew.Sprintf("not implemented by %v with firmware %v",
+			v.deviceInfo.SensorType, v.deviceInfo.FirmwareVersion)
+	}
+	return &UnsupportedError{Feature: feature, Reason: reason}
+}
+
+// checkSupported return *UnsupportedError, when
+// sensor do not implement command.
+func (v *Si7021) checkSupported(feature string, cmd []byte) error {
+	return v.checkCapability(feature, commandCapability(cmd))
+}
@@ -0,0 +1,85 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFirmwareCapabilities(t *testing.T) {
+	const si70xx = CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER | CAP_HEATER_LEVEL |
+		CAP_TEMP_FROM_PREVIOUS | CAP_FIRMWARE_REVISION
+	tests := []struct {
+		st   SensorType
+		fv   FirmwareVersion
+		caps Capability
+	}{
+		{SI_7021_TYPE, FIRMWARE_VER_2_0, si70xx},
+		{SI_7021_TYPE, FIRMWARE_VER_1_0, si70xx},
+		{SI_7021_TYPE, 0x30, CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER | CAP_FIRMWARE_REVISION},
+		{SI_7013_TYPE, FIRMWARE_VER_1_0, si70xx | CAP_ANALOG_IN},
+		{SI_7013_TYPE, 0x30, CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER |
+			CAP_FIRMWARE_REVISION | CAP_ANALOG_IN},
+		{SI_ENGINEERING_TYPE1, FIRMWARE_VER_1_0, CAP_HUMIDITY | CAP_TEMPERATURE | CAP_FIRMWARE_REVISION},
+		{SI_ENGINEERING_TYPE2, FIRMWARE_VER_2_0, CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER |
+			CAP_TEMP_FROM_PREVIOUS | CAP_FIRMWARE_REVISION},
+	}
+	for _, test := range tests {
+		if caps := GetFirmwareCapabilities(test.st, test.fv); caps != test.caps {
+			t.Errorf("GetFirmwareCapabilities(%v, %v) = %v, want %v", test.st, test.fv, caps, test.caps)
+		}
+		sim := NewSimulator()
+		sim.SetSensorType(test.st)
+		sim.SetFirmwareVersion(test.fv)
+		sensor := NewSi7021()
+		info, err := sensor.ReadDeviceInfo(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Capabilities != test.caps || sensor.GetCapabilities() != test.caps {
+			t.Errorf("%v with firmware %v: capabilities %v, want %v",
+				test.st, test.fv, info.Capabilities, test.caps)
+		}
+	}
+}
+
+func TestUnsupportedCommandNotSent(t *testing.T) {
+	sim := NewSimulator()
+	sim.SetFirmwareVersion(0x30)
+	sensor := NewSi7021()
+	if _, err := sensor.ReadDeviceInfo(sim); err != nil {
+		t.Fatal(err)
+	}
+	err := sensor.SetHeaterLevel(sim, HEATER_LEVEL_5)
+	var ue *UnsupportedError
+	if !errors.Is(err, ErrUnsupported) || !errors.As(err, &ue) {
+		t.Fatalf("expected UnsupportedError, got %v", err)
+	}
+	if sim.HeaterReg() != byte(HEATER_LEVEL_1) {
+		t.Errorf("heater register changed to 0x%02X", sim.HeaterReg())
+	}
+	// Temperature is measured separately, instead of unsupported command.
+	if _, _, err := sensor.ReadRelativeHumidityAndTemperature(sim); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,267 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// ChipModel denote sensor chip, compatible with Si7021 command set.
+type ChipModel int
+
+const (
+	CHIP_SI7021 ChipModel = iota
+	CHIP_SI7006
+	CHIP_SI7013
+	CHIP_SI7020
+	CHIP_HTU21D
+	CHIP_SHT21
+)
+
+// String define stringer interface.
+func (v ChipModel) String() string {
+	switch v {
+	case CHIP_SI7021:
+		return "Si7021"
+	case CHIP_SI7006:
+		return "Si7006"
+	case CHIP_SI7013:
+		return "Si7013"
+	case CHIP_SI7020:
+		return "Si7020"
+	case CHIP_HTU21D:
+		return "HTU21D"
+	case CHIP_SHT21:
+		return "SHT21"
+	default:
+		return "<unknown>"
+	}
+}
+
+// IDLayout denote electronic ID format.
+type IDLayout int
+
+const (
+	// Silicon Labs: SNA3..SNA0, each followed by CRC of all
+	// previous bytes, then SNB3, SNB2, CRC, SNB1, SNB0, CRC, where
+	// CRC cover all previous bytes of 2nd part.
+	// Serial number is SNA3..SNA0 SNB3..SNB0.
+	ID_LAYOUT_SILABS IDLayout = iota
+	// Sensirion: SNB3..SNB0, each followed by own CRC, then
+	// SNC1, SNC0, CRC, SNA1, SNA0, CRC, where CRC cover
+	// preceding pair of bytes only.
+	// Serial number is SNA1 SNA0 SNB3..SNB0 SNC1 SNC0.
+	ID_LAYOUT_SENSIRION
+)
+
+// ChipVariant describe, how chip differ from Si7021.
+// All chips share measurement, user register 1,
+// reset and electronic ID commands.
+type ChipVariant struct {
+	Model ChipModel
+	// Byte SNB3 of electronic ID denote sensor type.
+	SensorTypeInID bool
+	// Electronic ID format.
+	IDLayout IDLayout
+	// Low bits of measurement code, which carry
+	// status and must be cleared before conversion.
+	StatusBitsMask uint16
+	// Features chip implement. For Si70xx chips they
+	// are refined by sensor type and firmware revision,
+	// when sensor identity is read (see ReadDeviceInfo).
+	Capabilities Capability
+	// Reference temperature (*C) and RH temperature
+	// coefficient (%RH/*C) used to compensate humidity.
+	RHCompensationRefTemp float32
+	RHCompensationCoeff   float32
+}
+
+// chipVariants keep all known chips.
+var chipVariants = map[ChipModel]*ChipVariant{
+	CHIP_SI7021: {Model: CHIP_SI7021, SensorTypeInID: true, IDLayout: ID_LAYOUT_SILABS,
+		Capabilities:          GetCapabilities(SI_7021_TYPE),
+		RHCompensationRefTemp: RH_COMPENSATION_REF_TEMP, RHCompensationCoeff: RH_COMPENSATION_COEFF},
+	CHIP_SI7006: {Model: CHIP_SI7006, SensorTypeInID: true, IDLayout: ID_LAYOUT_SILABS,
+		Capabilities:          GetCapabilities(SI_7006_TYPE),
+		RHCompensationRefTemp: RH_COMPENSATION_REF_TEMP, RHCompensationCoeff: RH_COMPENSATION_COEFF},
+	CHIP_SI7013: {Model: CHIP_SI7013, SensorTypeInID: true, IDLayout: ID_LAYOUT_SILABS,
+		Capabilities:          GetCapabilities(SI_7013_TYPE),
+		RHCompensationRefTemp: RH_COMPENSATION_REF_TEMP, RHCompensationCoeff: RH_COMPENSATION_COEFF},
+	CHIP_SI7020: {Model: CHIP_SI7020, SensorTypeInID: true, IDLayout: ID_LAYOUT_SILABS,
+		Capabilities:          GetCapabilities(SI_7020_TYPE),
+		RHCompensationRefTemp: RH_COMPENSATION_REF_TEMP, RHCompensationCoeff: RH_COMPENSATION_COEFF},
+	// HTU21D and SHT21 use Sensirion ID format, report status
+	// in 2 lowest bits of result, have heater on/off bit only
+	// and specify RH temperature coefficient of -0.15%RH/*C at 25*C.
+	CHIP_HTU21D: {Model: CHIP_HTU21D, IDLayout: ID_LAYOUT_SENSIRION, StatusBitsMask: 0x0003,
+		Capabilities:          CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER,
+		RHCompensationRefTemp: 25, RHCompensationCoeff: -0.15},
+	CHIP_SHT21: {Model: CHIP_SHT21, IDLayout: ID_LAYOUT_SENSIRION, StatusBitsMask: 0x0003,
+		Capabilities:          CAP_HUMIDITY | CAP_TEMPERATURE | CAP_HEATER,
+		RHCompensationRefTemp: 25, RHCompensationCoeff: -0.15},
+}
+
+// GetChipVariant return copy of chip model description,
+// so caller can't change chips known to driver.
+func GetChipVariant(model ChipModel) (ChipVariant, error) {
+	cv, ok := chipVariants[model]
+	if !ok {
+		return ChipVariant{}, errors.New(spew.Sprintf("Unknown chip model %d", model))
+	}
+	return *cv, nil
+}
+
+// chipModelBySensorType return Si70xx chip by
+// sensor type read from electronic ID.
+func chipModelBySensorType(st SensorType) (ChipModel, bool) {
+	switch st {
+	case SI_7006_TYPE:
+		return CHIP_SI7006, true
+	case SI_7013_TYPE:
+		return CHIP_SI7013, true
+	case SI_7020_TYPE:
+		return CHIP_SI7020, true
+	case SI_7021_TYPE:
+		return CHIP_SI7021, true
+	default:
+		return 0, false
+	}
+}
+
+// GetConversionTime return datasheet maximum conversion
+// time of relative humidity and temperature for resolution.
+func (v ChipVariant) GetConversionTime(res UserRegFlag) (time.Duration, time.Duration) {
+	const ms = time.Millisecond
+	switch v.Model {
+	case CHIP_HTU21D:
+		switch res & RES_RH_TEMP_MASK {
+		case RES_RH_8BIT_TEMP_12BIT:
+			return 3 * ms, 13 * ms
+		case RES_RH_10BIT_TEMP_13BIT:
+			return 5 * ms, 25 * ms
+		case RES_RH_11BIT_TEMP_11BIT:
+			return 8 * ms, 7 * ms
+		default:
+			return 16 * ms, 50 * ms
+		}
+	case CHIP_SHT21:
+		switch res & RES_RH_TEMP_MASK {
+		case RES_RH_8BIT_TEMP_12BIT:
+			return 4 * ms, 22 * ms
+		case RES_RH_10BIT_TEMP_13BIT:
+			return 9 * ms, 43 * ms
+		case RES_RH_11BIT_TEMP_11BIT:
+			return 15 * ms, 11 * ms
+		default:
+			return 29 * ms, 85 * ms
+		}
+	default:
+		return GetConversionTime(res)
+	}
+}
+
+// chip return chip variant driver set up for.
+// Zero value Si7021 work with Si7021 chip.
+func (v *Si7021) chip() *ChipVariant {
+	if v.variant == nil {
+		return chipVariants[CHIP_SI7021]
+	}
+	return v.variant
+}
+
+// CompensateRelativeHumidity apply chip RH temperature
+// coefficient to relative humidity measured at temperature
+// away from reference one, and clamp result to 0-100% range.
+func (v ChipVariant) CompensateRelativeHumidity(rh, temp float32) float32 {
+	return compensateRelativeHumidity(rh, temp,
+		v.RHCompensationRefTemp, v.RHCompensationCoeff)
+}
+
+// SetChipModel tell driver which chip it work with,
+// so commands and timings are adapted to it.
+// Default is CHIP_SI7021.
+func (v *Si7021) SetChipModel(model ChipModel) error {
+	cv, err := GetChipVariant(model)
+	if err != nil {
+		return err
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.variant = &cv
+	v.deviceInfo = nil
+	return nil
+}
+
+// GetChipModel return chip model driver work with.
+func (v *Si7021) GetChipModel() ChipModel {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.chip().Model
+}
+
+// DetectChipModel identify chip by electronic ID and
+// adapt driver to it. Si70xx chips report sensor type in ID,
+// Si70xx engineering samples are recognized by firmware
+// revision command. All others are treated as SHT21, which
+// timings are safe for HTU21D as well (set CHIP_HTU21D
+// explicitly with SetChipModel to use faster timings).
+func (v *Si7021) DetectChipModel(bus Bus) (ChipModel, error) {
+	return v.DetectChipModelCtx(context.Background(), bus)
+}
+
+// DetectChipModelCtx is the same as DetectChipModel, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) DetectChipModelCtx(ctx context.Context, bus Bus) (ChipModel, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Detecting chip model...")
+	buf := make([]byte, 6)
+	err := v.withRetry(ctx, "electronic ID read", func() error {
+		return writeReadBytes(ctx, bus, CMD_READ_ID_2ND_PART, buf)
+	})
+	if err != nil {
+		return 0, err
+	}
+	st := SensorType(buf[0])
+	model, ok := chipModelBySensorType(st)
+	if !ok {
+		model = CHIP_SHT21
+		if st == SI_ENGINEERING_TYPE1 || st == SI_ENGINEERING_TYPE2 {
+			// Only Si70xx chips answer firmware revision request.
+			buf1 := make([]byte, 1)
+			err = writeReadBytes(ctx, bus, CMD_READ_FIRMWARE_REV, buf1)
+			if err == nil {
+				model = CHIP_SI7021
+			} else if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+		}
+	}
+	lg.Debugf("Chip detected: %v", model)
+	v.variant = chipVariants[model]
+	v.deviceInfo = nil
+	return model, nil
+}
@@ -0,0 +1,212 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+// corruptBus pass operations to simulator, but flip
+// byte at index of response to command cmd.
+type corruptBus struct {
+	sim     *Simulator
+	cmd     []byte
+	index   int
+	lastCmd []byte
+}
+
+func (v *corruptBus) WriteBytes(buf []byte) (int, error) {
+	v.lastCmd = append([]byte{}, buf...)
+	return v.sim.WriteBytes(buf)
+}
+
+func (v *corruptBus) ReadBytes(buf []byte) (int, error) {
+	n, err := v.sim.ReadBytes(buf)
+	if err == nil && bytes.Equal(v.lastCmd, v.cmd) && v.index < n {
+		buf[v.index] ^= 0xFF
+	}
+	return n, err
+}
+
+func newChipSimulator(t *testing.T, model ChipModel) *Simulator {
+	t.Helper()
+	sim := NewSimulator()
+	sim.SetSerialNumber(0x1122334455667788)
+	if err := sim.SetChipModel(model); err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func TestGetChipVariantCopy(t *testing.T) {
+	cv, err := GetChipVariant(CHIP_SI7021)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv.Capabilities = 0
+	if caps := NewSi7021().GetCapabilities(); caps != GetCapabilities(SI_7021_TYPE) {
+		t.Errorf("chip variant table changed via copy: capabilities %v", caps)
+	}
+}
+
+func TestDetectChipModel(t *testing.T) {
+	tests := []struct {
+		model    ChipModel
+		detected ChipModel
+	}{
+		{CHIP_SI7021, CHIP_SI7021},
+		{CHIP_SI7006, CHIP_SI7006},
+		{CHIP_SI7013, CHIP_SI7013},
+		{CHIP_SI7020, CHIP_SI7020},
+		// HTU21D can't be told from SHT21 by ID.
+		{CHIP_HTU21D, CHIP_SHT21},
+		{CHIP_SHT21, CHIP_SHT21},
+	}
+	for _, test := range tests {
+		sim := newChipSimulator(t, test.model)
+		sensor := NewSi7021()
+		model, err := sensor.DetectChipModel(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model != test.detected || sensor.GetChipModel() != test.detected {
+			t.Errorf("%v detected as %v, want %v", test.model, model, test.detected)
+		}
+	}
+	// Engineering samples answer firmware revision request.
+	for _, st := range []SensorType{SI_ENGINEERING_TYPE1, SI_ENGINEERING_TYPE2} {
+		sim := NewSimulator()
+		sim.SetSensorType(st)
+		model, err := NewSi7021().DetectChipModel(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model != CHIP_SI7021 {
+			t.Errorf("%v detected as %v, want %v", st, model, CHIP_SI7021)
+		}
+	}
+}
+
+func TestSensirionDeviceInfo(t *testing.T) {
+	for _, model := range []ChipModel{CHIP_HTU21D, CHIP_SHT21} {
+		sim := newChipSimulator(t, model)
+		sensor := NewSi7021()
+		if err := sensor.SetChipModel(model); err != nil {
+			t.Fatal(err)
+		}
+		info, err := sensor.ReadDeviceInfo(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Simulator clear SNC1 byte of Sensirion ID.
+		const sn = 0x1122334455660088
+		if info.Chip != model || info.SerialNumber != sn || info.FirmwareVersion != 0 ||
+			info.Capabilities != mustChipVariant(t, model).Capabilities {
+			t.Errorf("%v: unexpected device info %v", model, info)
+		}
+		if _, err := sensor.ReadFirmwareVersion(sim); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%v: expected unsupported firmware revision, got %v", model, err)
+		}
+	}
+}
+
+func TestSensirionIDCRC(t *testing.T) {
+	tests := []struct {
+		cmd   []byte
+		index int
+		field string
+	}{
+		{CMD_READ_ID_1ST_PART, 1, "SNB3"},
+		{CMD_READ_ID_1ST_PART, 7, "SNB0"},
+		{CMD_READ_ID_2ND_PART, 2, "SNC1-SNC0"},
+		{CMD_READ_ID_2ND_PART, 5, "SNA1-SNA0"},
+	}
+	for _, test := range tests {
+		bus := &corruptBus{sim: newChipSimulator(t, CHIP_SHT21),
+			cmd: test.cmd, index: test.index}
+		sensor := NewSi7021()
+		if err := sensor.SetChipModel(CHIP_SHT21); err != nil {
+			t.Fatal(err)
+		}
+		_, err := sensor.ReadSerialNumber(bus)
+		var crcErr *CRCError
+		if !errors.As(err, &crcErr) || crcErr.Field != test.field {
+			t.Errorf("byte %d of %v corrupted: expected CRC error of %s, got %v",
+				test.index, test.cmd, test.field, err)
+		}
+	}
+}
+
+func TestSensirionMeasurement(t *testing.T) {
+	for _, model := range []ChipModel{CHIP_HTU21D, CHIP_SHT21} {
+		sim := newChipSimulator(t, model)
+		sim.SetAmbient(21.3, 63.7)
+		sensor := NewSi7021()
+		if err := sensor.SetChipModel(model); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		m, err := sensor.ReadMeasurement(NewRecorder(sim, &buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.HumidityCode&mustChipVariant(t, model).StatusBitsMask != 0 || !m.CRCValid {
+			t.Errorf("%v: unexpected measurement %+v", model, m)
+		}
+		// Within 1 LSB of 12-bit RH code.
+		if math.Abs(float64(m.RelativeHumidity)-63.7) > 0.04 ||
+			math.Abs(float64(m.Temperature)-21.3) > 0.01 {
+			t.Errorf("%v: measured %v, %v", model, m.RelativeHumidity, m.Temperature)
+		}
+		// Temperature is measured separately, since chip
+		// lack temperature from previous RH measurement.
+		trs, err := LoadTranscript(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tempMeasured bool
+		for _, tr := range trs {
+			if tr.Kind == TRANSACTION_WRITE && bytes.Equal(tr.Data, CMD_TEMP_FROM_PREVIOUS) {
+				t.Errorf("%v: temperature from previous RH measurement requested", model)
+			}
+			if tr.Kind == TRANSACTION_WRITE && (bytes.Equal(tr.Data, CMD_TEMPRATURE) ||
+				bytes.Equal(tr.Data, CMD_TEMPRATURE_CSE)) {
+				tempMeasured = true
+			}
+		}
+		if !tempMeasured {
+			t.Errorf("%v: temperature is not measured", model)
+		}
+	}
+}
+
+func mustChipVariant(t *testing.T, model ChipModel) ChipVariant {
+	t.Helper()
+	cv, err := GetChipVariant(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cv
+}
@@ -0,0 +1,187 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CondensationConfig define when and how condensation
+// recovery run heater to dry sensor.
+type CondensationConfig struct {
+	// Relative humidity (in percents), which
+	// is considered as saturation (condensation).
+	SaturationRH float32
+	// How long humidity should stay at or above
+	// SaturationRH, to start heater cycle.
+	SaturationTime time.Duration
+	// Heating gradation used to dry sensor.
+	HeaterLevel HeaterLevel
+	// Heating duration (limited by heater controller maximum on-time).
+	HeatTime time.Duration
+	// Time to wait after heating, while sensor
+	// cool down to ambient temperature.
+	CoolDownTime time.Duration
+}
+
+// NewCondensationConfig returns condensation
+// recovery configuration with default values.
+func NewCondensationConfig() *CondensationConfig {
+	v := &CondensationConfig{
+		SaturationRH:   98,
+		SaturationTime: time.Minute * 10,
+		HeaterLevel:    HEATER_LEVEL_9,
+		HeatTime:       time.Second * 20,
+		CoolDownTime:   time.Minute,
+	}
+	return v
+}
+
+// RecoveryReading is a measurement taken
+// with condensation recovery mode.
+type RecoveryReading struct {
+	Time             time.Time
+	RelativeHumidity float32
+	Temperature      float32
+	// Valid is false, when reading taken during heater
+	// cycle or cool down period after it, so
+	// it does not reflect ambient environment.
+	Valid bool
+	// Recovering is true, when heater cycle is in progress.
+	Recovering bool
+}
+
+// CondensationRecovery is opt-in mode, which watch
+// relative humidity readings and, when humidity stay
+// near saturation for configured time, run heater cycle
+// to evaporate condensate, wait for sensor to cool down
+// and mark readings taken during and right after the cycle
+// as invalid. Heater cycle run in background, so readings
+// continue to be available (marked invalid) meanwhile.
+type CondensationRecovery struct {
+	mutex          sync.Mutex
+	sensor         *Si7021
+	bus            Bus
+	heater         *HeaterController
+	config         CondensationConfig
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+	saturatedSince time.Time
+	recovering     bool
+	invalidUntil   time.Time
+	lastErr        error
+}
+
+// NewCondensationRecovery returns new condensation recovery
+// mode for sensor connected to bus, which use heater controller
+// to dry sensor. Pass nil config to use default values.
+func NewCondensationRecovery(sensor *Si7021, bus Bus, heater *HeaterController,
+	config *CondensationConfig) *CondensationRecovery {
+
+	if config == nil {
+		config = NewCondensationConfig()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	v := &CondensationRecovery{sensor: sensor, bus: bus, heater: heater,
+		config: *config, ctx: ctx, cancel: cancel}
+	return v
+}
+
+// Read measure relative humidity and temperature
+// and start heater cycle, when it's time to.
+func (v *CondensationRecovery) Read() (*RecoveryReading, error) {
+	return v.ReadCtx(context.Background())
+}
+
+// ReadCtx is the same as Read, but
+// abort bus operations and waits, when ctx is done.
+func (v *CondensationRecovery) ReadCtx(ctx context.Context) (*RecoveryReading, error) {
+	rh, temp, err := v.sensor.ReadRelativeHumidityAndTemperatureCtx(ctx, v.bus)
+	if err != nil {
+		return nil, err
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	now := time.Now()
+	reading := &RecoveryReading{Time: now, RelativeHumidity: rh, Temperature: temp,
+		Valid: !v.recovering && !now.Before(v.invalidUntil), Recovering: v.recovering}
+	if reading.Valid {
+		if rh < v.config.SaturationRH {
+			v.saturatedSince = time.Time{}
+		} else if v.saturatedSince.IsZero() {
+			v.saturatedSince = now
+		} else if now.Sub(v.saturatedSince) >= v.config.SaturationTime {
+			v.startRecovery()
+		}
+	}
+	return reading, nil
+}
+
+// startRecovery run heater cycle in background.
+func (v *CondensationRecovery) startRecovery() {
+	lg.Infof("Humidity stay above %v%% for %v: starting condensation recovery",
+		v.config.SaturationRH, v.config.SaturationTime)
+	v.recovering = true
+	v.saturatedSince = time.Time{}
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		err := v.heater.Pulse(v.ctx, v.config.HeaterLevel, v.config.HeatTime)
+		if err != nil {
+			lg.Warnf("Condensation recovery heater cycle failed: %v", err)
+		}
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		v.recovering = false
+		// Unsupported heater is never switched on,
+		// so there is nothing to cool down.
+		if !errors.Is(err, ErrUnsupported) {
+			v.invalidUntil = time.Now().Add(v.config.CoolDownTime)
+		}
+		v.lastErr = err
+	}()
+}
+
+// Recovering return true, when heater cycle is in progress.
+func (v *CondensationRecovery) Recovering() bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.recovering
+}
+
+// LastError return error of last heater cycle, if any.
+func (v *CondensationRecovery) LastError() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.lastErr
+}
+
+// Close interrupt heater cycle in progress
+// (heater is switched off) and wait it to finish.
+func (v *CondensationRecovery) Close() {
+	v.cancel()
+	v.wg.Wait()
+}
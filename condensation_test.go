@@ -0,0 +1,105 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCondensationRecovery(t *testing.T) {
+	sim := NewSimulator()
+	sim.SetAmbient(20, 99)
+	sensor := NewSi7021()
+	heater := NewHeaterController(sensor, sim, 0)
+	config := &CondensationConfig{SaturationRH: 98, SaturationTime: 0,
+		HeaterLevel: HEATER_LEVEL_5, HeatTime: 100 * time.Millisecond,
+		CoolDownTime: 100 * time.Millisecond}
+	recovery := NewCondensationRecovery(sensor, sim, heater, config)
+	defer recovery.Close()
+
+	read := func() *RecoveryReading {
+		t.Helper()
+		reading, err := recovery.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reading
+	}
+	// First saturated reading start saturation period,
+	// second one (after zero saturation time) start heater cycle.
+	for i := 0; i < 2; i++ {
+		if r := read(); !r.Valid || r.Recovering {
+			t.Fatalf("reading %d before recovery: %+v", i, r)
+		}
+	}
+	if !recovery.Recovering() {
+		t.Fatal("recovery is not started")
+	}
+	if r := read(); r.Valid || !r.Recovering {
+		t.Errorf("reading during heater cycle: %+v", r)
+	}
+	deadline := time.Now().Add(time.Second)
+	for recovery.Recovering() {
+		if time.Now().After(deadline) {
+			t.Fatal("heater cycle is not finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if heaterEnabled(sim) {
+		t.Error("heater is left on after heater cycle")
+	}
+	if err := recovery.LastError(); err != nil {
+		t.Errorf("heater cycle failed: %v", err)
+	}
+	// Sensor cool down.
+	sim.SetAmbient(20, 60)
+	if r := read(); r.Valid || r.Recovering {
+		t.Errorf("reading during cool-down: %+v", r)
+	}
+	time.Sleep(config.CoolDownTime)
+	if r := read(); !r.Valid || r.Recovering {
+		t.Errorf("reading after cool-down: %+v", r)
+	}
+}
+
+func TestCondensationRecoveryClose(t *testing.T) {
+	sim := NewSimulator()
+	sim.SetAmbient(20, 100)
+	sensor := NewSi7021()
+	config := NewCondensationConfig()
+	config.SaturationTime = 0
+	recovery := NewCondensationRecovery(sensor, sim, NewHeaterController(sensor, sim, 0), config)
+	for i := 0; i < 2; i++ {
+		if _, err := recovery.Read(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !recovery.Recovering() {
+		t.Fatal("recovery is not started")
+	}
+	// Close interrupt 20 seconds heater cycle.
+	recovery.Close()
+	if heaterEnabled(sim) {
+		t.Error("heater is left on after close")
+	}
+}
@@ -0,0 +1,141 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// DeviceInfo keep sensor identity.
+type DeviceInfo struct {
+	// Chip model driver is set up for.
+	Chip         ChipModel
+	SerialNumber int64
+	// Byte SNB3 of electronic ID, which denote
+	// sensor type for Si70xx chips only.
+	SensorType SensorType
+	// Firmware revision, zero when chip
+	// do not report it.
+	FirmwareVersion FirmwareVersion
+	// Electronic ID raw bytes including CRCs.
+	RawID        SerialNumberRaw
+	Capabilities Capability
+}
+
+// String define stringer interface.
+func (v DeviceInfo) String() string {
+	return spew.Sprintf("%v, serial number 0x%016X, firmware %v, capabilities: %v",
+		v.Chip, uint64(v.SerialNumber), v.FirmwareVersion, v.Capabilities)
+}
+
+// MarshalJSON implement json.Marshaler interface.
+// Both human readable names and raw codes are encoded.
+func (v DeviceInfo) MarshalJSON() ([]byte, error) {
+	var raw bytes.Buffer
+	err := binary.Write(&raw, binary.BigEndian, &v.RawID)
+	if err != nil {
+		return nil, err
+	}
+	var st string
+	// Sensor type name make sense for Si70xx chips only.
+	if cv, err := GetChipVariant(v.Chip); err == nil && cv.SensorTypeInID {
+		st = v.SensorType.String()
+	}
+	return json.Marshal(struct {
+		Chip                string   `json:"chip"`
+		SerialNumber        string   `json:"serial_number"`
+		SensorType          string   `json:"sensor_type,omitempty"`
+		SensorTypeCode      byte     `json:"sensor_type_code"`
+		FirmwareVersion     string   `json:"firmware_version"`
+		FirmwareVersionCode byte     `json:"firmware_version_code"`
+		RawID               HexBytes `json:"raw_id"`
+		Capabilities        []string `json:"capabilities"`
+	}{
+		Chip:                v.Chip.String(),
+		SensorType:          st,
+		SerialNumber:        spew.Sprintf("%016X", uint64(v.SerialNumber)),
+		SensorTypeCode:      byte(v.SensorType),
+		FirmwareVersion:     v.FirmwareVersion.String(),
+		FirmwareVersionCode: byte(v.FirmwareVersion),
+		RawID:               raw.Bytes(),
+		Capabilities:        v.Capabilities.Names(),
+	})
+}
+
+// ReadDeviceInfo return sensor identity: serial number, sensor type,
+// firmware version, raw electronic ID and capabilities. Identity is
+// read once (with CRC verification) and cached, until sensor reset.
+// Each call return own copy of identity.
+func (v *Si7021) ReadDeviceInfo(bus Bus) (*DeviceInfo, error) {
+	return v.ReadDeviceInfoCtx(context.Background(), bus)
+}
+
+// ReadDeviceInfoCtx is the same as ReadDeviceInfo, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadDeviceInfoCtx(ctx context.Context, bus Bus) (*DeviceInfo, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.readDeviceInfo(ctx, bus)
+}
+
+// readDeviceInfo return copy of cached sensor identity,
+// so caller can't change capabilities driver consult.
+func (v *Si7021) readDeviceInfo(ctx context.Context, bus Bus) (*DeviceInfo, error) {
+	if v.deviceInfo != nil {
+		info := *v.deviceInfo
+		return &info, nil
+	}
+	lg.Debug("Reading device info...")
+	raw, sn, err := v.readSerialNumber(ctx, bus)
+	if err != nil {
+		return nil, err
+	}
+	var fv FirmwareVersion
+	if v.chip().Capabilities.Has(CAP_FIRMWARE_REVISION) {
+		fv, err = v.readFirmwareVersion(ctx, bus)
+		if err != nil {
+			return nil, err
+		}
+	}
+	st := SensorType(raw.SNB3)
+	caps := v.chip().Capabilities
+	if v.chip().SensorTypeInID {
+		caps = GetFirmwareCapabilities(st, fv)
+	}
+	v.deviceInfo = &DeviceInfo{Chip: v.chip().Model, SerialNumber: sn, SensorType: st,
+		FirmwareVersion: fv, RawID: *raw, Capabilities: caps}
+	info := *v.deviceInfo
+	return &info, nil
+}
+
+// InvalidateDeviceInfo drop cached sensor identity,
+// so it will be read from sensor next time.
+func (v *Si7021) InvalidateDeviceInfo() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.deviceInfo = nil
+}
@@ -0,0 +1,175 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"errors"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Error classes, which could be
+// checked with errors.Is function.
+var (
+	// ErrCRCMismatch denote that CRC sent by
+	// sensor differ from calculated one.
+	ErrCRCMismatch = errors.New("CRC mismatch")
+	// ErrBusIO denote failure of bus transaction.
+	ErrBusIO = errors.New("bus I/O error")
+	// ErrConversionTimeout denote that measurement
+	// result is not ready in time.
+	ErrConversionTimeout = errors.New("conversion timeout")
+	// ErrUnsupported denote that device
+	// does not implement requested feature.
+	ErrUnsupported = errors.New("unsupported by device")
+	// ErrVerifyFailed denote that register value
+	// read back differ from value written.
+	ErrVerifyFailed = errors.New("register verification failed")
+)
+
+// CRCError keep details of CRC verification failure.
+type CRCError struct {
+	// Field which CRC protect (for instance
+	// "humidity", "temperature" or "SNA3").
+	Field string
+	// Expected is CRC calculated by driver.
+	Expected byte
+	// Actual is CRC received from sensor.
+	Actual byte
+}
+
+// Error implement error interface.
+func (e *CRCError) Error() string {
+	return spew.Sprintf("CRCs doesn't match for %s: CRC from sensor (0x%0X) != calculated CRC (0x%0X)",
+		e.Field, e.Actual, e.Expected)
+}
+
+// Is make CRCError match ErrCRCMismatch.
+func (e *CRCError) Is(target error) bool {
+	return target == ErrCRCMismatch
+}
+
+// BusError wrap error returned by bus.
+type BusError struct {
+	// Op is operation failed: "write", "read" or "write-read".
+	Op string
+	// Err is original bus error.
+	Err error
+}
+
+// Error implement error interface.
+func (e *BusError) Error() string {
+	return spew.Sprintf("Bus %s failed: %s", e.Op, e.Err.Error())
+}
+
+// Unwrap return original bus error.
+func (e *BusError) Unwrap() error {
+	return e.Err
+}
+
+// Is make BusError match ErrBusIO.
+func (e *BusError) Is(target error) bool {
+	return target == ErrBusIO
+}
+
+// TimeoutError denote that measurement
+// result was not received in time.
+type TimeoutError struct {
+	// Timeout is time spent waiting.
+	Timeout time.Duration
+	// LastErr is last error returned by bus,
+	// while driver was waiting for result.
+	LastErr error
+}
+
+// Error implement error interface.
+func (e *TimeoutError) Error() string {
+	if e.LastErr != nil {
+		return spew.Sprintf("Measurement is not ready after %v of polling: %s",
+			e.Timeout, e.LastErr.Error())
+	}
+	return spew.Sprintf("Measurement is not ready after %v", e.Timeout)
+}
+
+// Unwrap return last bus error, so bus
+// failure behind timeout could be checked too.
+func (e *TimeoutError) Unwrap() error {
+	return e.LastErr
+}
+
+// Is make TimeoutError match ErrConversionTimeout.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrConversionTimeout
+}
+
+// UnsupportedError denote that device
+// does not implement requested feature.
+type UnsupportedError struct {
+	// Feature requested.
+	Feature string
+	// Reason explain why feature is unavailable.
+	Reason string
+}
+
+// Error implement error interface.
+func (e *UnsupportedError) Error() string {
+	if e.Reason != "" {
+		return spew.Sprintf("Feature %q is not supported: %s", e.Feature, e.Reason)
+	}
+	return spew.Sprintf("Feature %q is not supported", e.Feature)
+}
+
+// Is make UnsupportedError match ErrUnsupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// VerifyError denote that value read back
+// from register differ from value written.
+type VerifyError struct {
+	// Register name.
+	Register string
+	// Written is value sent to sensor.
+	Written byte
+	// ReadBack is value read after write.
+	ReadBack byte
+}
+
+// Error implement error interface.
+func (e *VerifyError) Error() string {
+	return spew.Sprintf("Verification of %s failed: written 0x%02X, read back 0x%02X",
+		e.Register, e.Written, e.ReadBack)
+}
+
+// Is make VerifyError match ErrVerifyFailed.
+func (e *VerifyError) Is(target error) bool {
+	return target == ErrVerifyFailed
+}
+
+// wrapBusError wrap non-nil bus error to BusError.
+func wrapBusError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &BusError{Op: op, Err: err}
+}
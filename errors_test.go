@@ -0,0 +1,61 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeoutErrorUnwrap(t *testing.T) {
+	sensor := NewSi7021()
+	sensor.SetMeasureMode(MEASURE_NO_HOLD_POLLING)
+	sensor.SetPolling(time.Millisecond, 5*time.Millisecond)
+	sim := NewSimulator()
+	// Conversion never complete in time, so all reads are NACKed.
+	sim.SetConversionTime(time.Second)
+	_, err := sensor.ReadTemperature(sim)
+	var timeoutErr *TimeoutError
+	var busErr *BusError
+	if !errors.Is(err, ErrConversionTimeout) || !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if !errors.Is(err, ErrBusIO) || !errors.As(err, &busErr) {
+		t.Errorf("bus error behind timeout is not available: %v", err)
+	}
+	if err := (&TimeoutError{Timeout: time.Second}); errors.Is(err, ErrBusIO) {
+		t.Error("timeout without bus error match ErrBusIO")
+	}
+}
+
+func TestTimeoutRetryable(t *testing.T) {
+	err := &TimeoutError{Timeout: time.Second,
+		LastErr: &BusError{Op: "read", Err: errors.New("NACK")}}
+	if NewRetryPolicy(3, 0).IsRetryable(err) {
+		t.Error("timeout is retried by default policy")
+	}
+	policy := &RetryPolicy{MaxAttempts: 3, Retryable: []error{ErrConversionTimeout}}
+	if !policy.IsRetryable(err) {
+		t.Error("timeout is not retried, while listed as retryable")
+	}
+}
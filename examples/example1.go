@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"syscall"
 	"time"
@@ -17,15 +18,25 @@ var lg = logger.NewPackageLogger("main",
 	// logger.InfoLevel,
 )
 
+var simulate = flag.Bool("sim", false, "use software sensor simulator instead of i2c-bus")
+
 func main() {
 	defer logger.FinalizeLogger()
-	// Create new connection to i2c-bus on 1 line with address 0x40.
-	// Use i2cdetect utility to find device address over the i2c-bus
-	i2c, err := i2c.NewI2C(0x40, 0)
-	if err != nil {
-		lg.Fatal(err)
+	flag.Parse()
+	var bus si7021.Bus
+	if *simulate {
+		// Run with software sensor, when no hardware available.
+		bus = si7021.NewSimulator()
+	} else {
+		// Create new connection to i2c-bus on 1 line with address 0x40.
+		// Use i2cdetect utility to find device address over the i2c-bus
+		i2c, err := i2c.NewI2C(0x40, 0)
+		if err != nil {
+			lg.Fatal(err)
+		}
+		defer i2c.Close()
+		bus = i2c
 	}
-	defer i2c.Close()
 
 	lg.Notify("**********************************************************************************************")
 	lg.Notify("*** !!! READ THIS !!!")
@@ -38,15 +49,21 @@ func main() {
 	// logger.ChangePackageLogLevel("si7021", logger.InfoLevel)
 
 	sensor := si7021.NewSi7021()
-	err = sensor.Reset(i2c)
+	err := sensor.Reset(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
+	// Adapt driver to Si70xx, HTU21D or SHT21 chip found.
+	model, err := sensor.DetectChipModel(bus)
+	if err != nil {
+		lg.Fatal(err)
+	}
+	lg.Infof("Chip model = %v", model)
 
 	lg.Notify("**********************************************************************************************")
 	lg.Notify("*** Read sensor identity and states")
 	lg.Notify("**********************************************************************************************")
-	vlow, err := sensor.GetVoltageLow(i2c)
+	vlow, err := sensor.GetVoltageLow(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
@@ -55,56 +72,61 @@ func main() {
 	} else {
 		lg.Infof("Voltage status = OK")
 	}
-	mr, err := sensor.GetMeasureResolution(i2c)
+	mr, err := sensor.GetMeasureResolution(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Measure resolution = %v", mr)
-	hs, err := sensor.GetHeaterStatus(i2c)
+	hs, err := sensor.GetHeaterStatus(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Heater ON status = %v", hs)
-	hl, err := sensor.GetHeaterLevel(i2c)
+	hl, err := sensor.GetHeaterLevel(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Heater level = %v", hl)
-	b, err := sensor.ReadFirmwareVersion(i2c)
+	b, err := sensor.ReadFirmwareVersion(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Revision = %v", b)
-	sn, err := sensor.ReadSerialNumber(i2c)
+	sn, err := sensor.ReadSerialNumber(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Serial number = %v", sn)
-	st, err := sensor.ReadSensoreType(i2c)
+	st, err := sensor.ReadSensoreType(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Sensor type = %v", st)
+	di, err := sensor.ReadDeviceInfo(bus)
+	if err != nil {
+		lg.Fatal(err)
+	}
+	lg.Infof("Device info = %v", di)
 
 	lg.Notify("**********************************************************************************************")
 	lg.Notify("*** Measure humidity and temperature")
 	lg.Notify("**********************************************************************************************")
-	urh, ut, err := sensor.ReadUncompHumidityAndTemprature(i2c)
+	urh, ut, err := sensor.ReadUncompHumidityAndTemprature(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Humidity and temprature uncompensated = %v, %v", urh, ut)
-	rh, err := sensor.ReadRelativeHumidity(i2c)
+	rh, err := sensor.ReadRelativeHumidity(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Relative humidity = %v%%", rh)
-	t, err := sensor.ReadTemperature(i2c)
+	t, err := sensor.ReadTemperature(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Temprature in celsius = %v*C", t)
-	rh, t, err = sensor.ReadRelativeHumidityAndTemperature(i2c)
+	rh, t, err = sensor.ReadRelativeHumidityAndTemperature(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
@@ -126,46 +148,21 @@ func main() {
 	// run goroutine waiting for OS termination events, including keyboard Ctrl+C
 	shell.CloseContextOnSignals(cancel, done, signals...)
 
-	err = sensor.SetHeaterLevel(i2c, si7021.HEATER_LEVEL_8)
-	if err != nil {
-		lg.Fatal(err)
-	}
-	err = sensor.SetHeaterStatus(i2c, true)
-	if err != nil {
-		lg.Fatal(err)
-	}
-	hs, err = sensor.GetHeaterStatus(i2c)
-	if err != nil {
-		lg.Fatal(err)
-	}
-	hl, err = sensor.GetHeaterLevel(i2c)
-	if err != nil {
-		lg.Fatal(err)
-	}
-	lg.Infof("Heater ON status and level = %v, %v", hs, hl)
+	// Heater controller switch heater off, when pulse is over
+	// or interrupted, and never keep it on longer than 10 sec.
+	heater := si7021.NewHeaterController(sensor, bus, time.Second*10)
 	pause := time.Second * 3
-	lg.Infof("Waiting %v...", pause)
-	select {
-	// Check for termination request.
-	case <-ctx.Done():
-		err = sensor.SetHeaterStatus(i2c, false)
-		if err != nil {
-			lg.Fatal(err)
-		}
-		lg.Fatal(ctx.Err())
-	// Sleep 10 sec.
-	case <-time.After(pause):
-	}
-	err = sensor.SetHeaterStatus(i2c, false)
+	lg.Infof("Heating with %v for %v...", si7021.HEATER_LEVEL_8, pause)
+	err = heater.Pulse(ctx, si7021.HEATER_LEVEL_8, pause)
 	if err != nil {
 		lg.Fatal(err)
 	}
-	hs, err = sensor.GetHeaterStatus(i2c)
+	hs, err = sensor.GetHeaterStatus(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
 	lg.Infof("Heater ON status = %v", hs)
-	rh, t, err = sensor.ReadRelativeHumidityAndTemperature(i2c)
+	rh, t, err = sensor.ReadRelativeHumidityAndTemperature(bus)
 	if err != nil {
 		lg.Fatal(err)
 	}
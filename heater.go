@@ -0,0 +1,245 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// DEFAULT_HEATER_MAX_ON_TIME limit continuous heating,
+// unless other value is given to heater controller.
+const DEFAULT_HEATER_MAX_ON_TIME = time.Second * 30
+
+// heaterOffTimeout limit time to switch heater off,
+// when heating program is finished or cancelled.
+const heaterOffTimeout = time.Second * 2
+
+// HeaterController run timed heating pulses and periodic
+// duty cycles on sensor internal heater. Controller always
+// switch heater off on exit (including context cancellation)
+// and never keep it on longer than maximum on-time.
+type HeaterController struct {
+	mutex     sync.Mutex
+	sensor    *Si7021
+	bus       Bus
+	maxOnTime time.Duration
+	running   bool
+	// Heating in progress: level and
+	// time when heater was switched on.
+	heating      bool
+	heatingLevel HeaterLevel
+	heatingSince time.Time
+	// Supply voltage (V), energy (J) consumed by heater
+	// so far and time since heating energy is not counted.
+	voltage     float64
+	energy      float64
+	energySince time.Time
+}
+
+// NewHeaterController returns new heater controller for sensor
+// connected to bus. Heater continuous on-time will not exceed
+// maxOnTime; zero value select DEFAULT_HEATER_MAX_ON_TIME.
+func NewHeaterController(sensor *Si7021, bus Bus, maxOnTime time.Duration) *HeaterController {
+	if maxOnTime <= 0 {
+		maxOnTime = DEFAULT_HEATER_MAX_ON_TIME
+	}
+	v := &HeaterController{sensor: sensor, bus: bus, maxOnTime: maxOnTime,
+		voltage: HEATER_NOMINAL_VOLTAGE}
+	return v
+}
+
+// GetMaxOnTime return maximum continuous heater on-time.
+func (v *HeaterController) GetMaxOnTime() time.Duration {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.maxOnTime
+}
+
+// start mark controller busy, since only
+// one heating program could run at once.
+func (v *HeaterController) start() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.running {
+		return errors.New("Heater controller is busy with another heating program")
+	}
+	v.running = true
+	return nil
+}
+
+func (v *HeaterController) stop() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.running = false
+}
+
+func (v *HeaterController) setHeating(heating bool, level HeaterLevel) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.heating {
+		v.energy += v.heatingEnergy()
+	}
+	v.heating = heating
+	v.heatingLevel = level
+	v.heatingSince = time.Now()
+	v.energySince = v.heatingSince
+}
+
+// heatingEnergy return energy (J) consumed by heating in progress.
+func (v *HeaterController) heatingEnergy() float64 {
+	power := GetHeaterPower(v.heatingLevel, v.voltage) / 1000
+	return power * time.Since(v.energySince).Seconds()
+}
+
+// SetSupplyVoltage define sensor supply voltage (V), used to
+// estimate heater energy consumption. Default is 3.3V.
+func (v *HeaterController) SetSupplyVoltage(voltage float64) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.voltage = voltage
+}
+
+// GetEnergy return estimated energy (J) consumed
+// by heater since controller creation or last reset.
+func (v *HeaterController) GetEnergy() float64 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	energy := v.energy
+	if v.heating {
+		energy += v.heatingEnergy()
+	}
+	return energy
+}
+
+// ResetEnergy reset heater energy counter.
+func (v *HeaterController) ResetEnergy() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.energy = 0
+	v.energySince = time.Now()
+}
+
+// GetHeatingState return true, if heater is switched on by
+// controller now, with heater level and time elapsed since
+// heater was switched on.
+func (v *HeaterController) GetHeatingState() (bool, HeaterLevel, time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if !v.heating {
+		return false, 0, 0
+	}
+	return true, v.heatingLevel, time.Since(v.heatingSince)
+}
+
+// switchOff disable heater even if context is already cancelled.
+func (v *HeaterController) switchOff() error {
+	ctx, cancel := context.WithTimeout(context.Background(), heaterOffTimeout)
+	defer cancel()
+	lg.Debug("Heater controller: switching heater off...")
+	return v.sensor.SetHeaterStatusCtx(ctx, v.bus, false)
+}
+
+// heat switch heater on at level for onTime, then off.
+// Chips without heater control register heat at fixed
+// power, so level is ignored there.
+func (v *HeaterController) heat(ctx context.Context, level HeaterLevel,
+	onTime time.Duration) (err error) {
+
+	if onTime > v.maxOnTime {
+		lg.Warnf("Heater controller: on-time %v is cut to maximum %v", onTime, v.maxOnTime)
+		onTime = v.maxOnTime
+	}
+	if v.sensor.GetCapabilities().Has(CAP_HEATER_LEVEL) {
+		err = v.sensor.SetHeaterLevelCtx(ctx, v.bus, level)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Chip has heater on/off only, which
+		// is treated as lowest heater level.
+		lg.Debugf("Heater controller: heater level is not supported, %v is used instead of %v",
+			HEATER_LEVEL_1, level)
+		level = HEATER_LEVEL_1
+	}
+	// Whatever happen next, heater must be switched off.
+	defer func() {
+		err2 := v.switchOff()
+		if err == nil {
+			err = err2
+		}
+	}()
+	err = v.sensor.SetHeaterStatusCtx(ctx, v.bus, true)
+	if err != nil {
+		return err
+	}
+	v.setHeating(true, level)
+	defer v.setHeating(false, level)
+	lg.Debugf("Heater controller: heating with %v for %v", level, onTime)
+	return sleepContext(ctx, onTime)
+}
+
+// Pulse switch heater on at level for duration and then off.
+// Function blocks until pulse is over or ctx is done.
+// Duration longer than maximum on-time is cut to it.
+func (v *HeaterController) Pulse(ctx context.Context, level HeaterLevel, duration time.Duration) error {
+	err := v.start()
+	if err != nil {
+		return err
+	}
+	defer v.stop()
+	return v.heat(ctx, level, duration)
+}
+
+// DutyCycle periodically switch heater on at level for onTime
+// within each period. Run cycles number of periods, or
+// until ctx is done, if cycles is zero. Function blocks
+// until program is over; heater is off on return.
+func (v *HeaterController) DutyCycle(ctx context.Context, level HeaterLevel,
+	onTime, period time.Duration, cycles int) error {
+
+	if onTime <= 0 || onTime >= period {
+		return errors.New(spew.Sprintf(
+			"Heater on-time %v should be positive and less than period %v",
+			onTime, period))
+	}
+	err := v.start()
+	if err != nil {
+		return err
+	}
+	defer v.stop()
+	for i := 0; cycles == 0 || i < cycles; i++ {
+		err = v.heat(ctx, level, onTime)
+		if err != nil {
+			return err
+		}
+		err = sleepContext(ctx, period-onTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
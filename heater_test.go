@@ -0,0 +1,155 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func heaterEnabled(sim *Simulator) bool {
+	return UserRegFlag(sim.UserReg())&HEATER_ENABLED != 0
+}
+
+// waitHeating wait until controller switch heater on.
+func waitHeating(t *testing.T, heater *HeaterController) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if heating, _, _ := heater.GetHeatingState(); heating {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("heater is not switched on")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPulseCancelled(t *testing.T) {
+	sim := NewSimulator()
+	heater := NewHeaterController(NewSi7021(), sim, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- heater.Pulse(ctx, HEATER_LEVEL_3, 10*time.Second)
+	}()
+	waitHeating(t, heater)
+	if !heaterEnabled(sim) || sim.HeaterReg() != byte(HEATER_LEVEL_3) {
+		t.Errorf("heater is not on at %v: user reg 0x%02X, heater reg 0x%02X",
+			HEATER_LEVEL_3, sim.UserReg(), sim.HeaterReg())
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+	if heaterEnabled(sim) {
+		t.Error("heater is left on after cancellation")
+	}
+	if heating, _, _ := heater.GetHeatingState(); heating {
+		t.Error("controller report heating after cancellation")
+	}
+}
+
+func TestPulseMaxOnTime(t *testing.T) {
+	sim := NewSimulator()
+	const maxOnTime = 50 * time.Millisecond
+	heater := NewHeaterController(NewSi7021(), sim, maxOnTime)
+	start := time.Now()
+	if err := heater.Pulse(context.Background(), HEATER_LEVEL_1, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < maxOnTime || elapsed > 10*maxOnTime {
+		t.Errorf("pulse take %v, while maximum on-time is %v", elapsed, maxOnTime)
+	}
+	if heaterEnabled(sim) {
+		t.Error("heater is left on after pulse")
+	}
+	if heater.GetEnergy() <= 0 {
+		t.Error("heater energy is not counted")
+	}
+}
+
+func TestDutyCycle(t *testing.T) {
+	sim := NewSimulator()
+	heater := NewHeaterController(NewSi7021(), sim, 0)
+	err := heater.DutyCycle(context.Background(), HEATER_LEVEL_2,
+		10*time.Millisecond, 10*time.Millisecond, 1)
+	if err == nil {
+		t.Error("expected error for on-time equal to period")
+	}
+	if err := heater.DutyCycle(context.Background(), HEATER_LEVEL_2,
+		10*time.Millisecond, 20*time.Millisecond, 2); err != nil {
+		t.Fatal(err)
+	}
+	if heaterEnabled(sim) {
+		t.Error("heater is left on after duty cycle")
+	}
+}
+
+func TestHeaterControllerBusy(t *testing.T) {
+	sim := NewSimulator()
+	heater := NewHeaterController(NewSi7021(), sim, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- heater.Pulse(ctx, HEATER_LEVEL_1, 10*time.Second)
+	}()
+	waitHeating(t, heater)
+	if err := heater.Pulse(context.Background(), HEATER_LEVEL_1, time.Millisecond); err == nil {
+		t.Error("expected error for concurrent heating program")
+	}
+	cancel()
+	<-done
+	if heaterEnabled(sim) {
+		t.Error("heater is left on")
+	}
+}
+
+func TestPulseWithoutHeaterLevel(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.SetChipModel(CHIP_HTU21D); err != nil {
+		t.Fatal(err)
+	}
+	sensor := NewSi7021()
+	if err := sensor.SetChipModel(CHIP_HTU21D); err != nil {
+		t.Fatal(err)
+	}
+	heater := NewHeaterController(sensor, sim, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- heater.Pulse(ctx, HEATER_LEVEL_9, 10*time.Second)
+	}()
+	waitHeating(t, heater)
+	// Heater on/off only: lowest level is in effect.
+	if _, level, _ := heater.GetHeatingState(); level != HEATER_LEVEL_1 {
+		t.Errorf("heating level %v, expected %v", level, HEATER_LEVEL_1)
+	}
+	cancel()
+	<-done
+	if heaterEnabled(sim) {
+		t.Error("heater is left on")
+	}
+}
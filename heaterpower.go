@@ -0,0 +1,75 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+// HEATER_NOMINAL_VOLTAGE is supply voltage, which
+// typical heater current is documented for.
+const HEATER_NOMINAL_VOLTAGE = 3.3
+
+// Typical heater current (mA) documented by datasheet
+// for some of heater levels. Other levels are interpolated.
+var heaterCurrentDocumented = map[HeaterLevel]float64{
+	HEATER_LEVEL_1:  3.09,
+	HEATER_LEVEL_2:  9.18,
+	HEATER_LEVEL_3:  15.24,
+	HEATER_LEVEL_5:  27.39,
+	HEATER_LEVEL_9:  51.69,
+	HEATER_LEVEL_16: 94.2,
+}
+
+// heaterCurrent return typical heater current in mA at
+// 3.3V supply for heater level, linearly interpolated
+// between levels documented by datasheet.
+func heaterCurrent(level HeaterLevel) float64 {
+	level &= HEATER_LEVEL_MASK
+	if c, ok := heaterCurrentDocumented[level]; ok {
+		return c
+	}
+	lower, upper := level, level
+	for ; ; lower-- {
+		if _, ok := heaterCurrentDocumented[lower]; ok {
+			break
+		}
+	}
+	for ; ; upper++ {
+		if _, ok := heaterCurrentDocumented[upper]; ok {
+			break
+		}
+	}
+	c1, c2 := heaterCurrentDocumented[lower], heaterCurrentDocumented[upper]
+	return c1 + (c2-c1)*float64(level-lower)/float64(upper-lower)
+}
+
+// GetHeaterCurrent return typical heater current (mA) for heater
+// level at supply voltage (V). Datasheet specify current at 3.3V for
+// levels 1, 2, 3, 5, 9 and 16 only; other levels are interpolated.
+// Heater is treated as resistive load, so current is proportional
+// to supply voltage.
+func GetHeaterCurrent(level HeaterLevel, voltage float64) float64 {
+	return heaterCurrent(level) * voltage / HEATER_NOMINAL_VOLTAGE
+}
+
+// GetHeaterPower return typical heater power (mW) for
+// heater level at supply voltage (V).
+func GetHeaterPower(level HeaterLevel, voltage float64) float64 {
+	return GetHeaterCurrent(level, voltage) * voltage
+}
@@ -0,0 +1,132 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Measurement is a complete record of relative humidity
+// and temperature measurement: raw sensor codes,
+// converted values and provenance (sensor configuration,
+// CRC verification result and timing), sufficient to
+// reprocess data later with improved conversion formulas.
+type Measurement struct {
+	// Raw 16-bit sensor codes.
+	HumidityCode    uint16 `json:"humidity_code"`
+	TemperatureCode uint16 `json:"temperature_code"`
+	// Converted values.
+	RelativeHumidity RelativeHumidity `json:"relative_humidity"`
+	Temperature      Temperature      `json:"temperature"`
+	// Uncertainty (+/-) of converted values estimated
+	// from chip specification, in %RH and *C.
+	RelativeHumidityUncertainty float32 `json:"relative_humidity_uncertainty"`
+	TemperatureUncertainty      float32 `json:"temperature_uncertainty"`
+	// Measure resolution active during measurement.
+	Resolution UserRegFlag `json:"resolution"`
+	// Heater state during measurement. Heater level is nil,
+	// when chip has no heater control register to read it from.
+	HeaterEnabled bool         `json:"heater_enabled"`
+	HeaterLevel   *HeaterLevel `json:"heater_level,omitempty"`
+	// CRCs sent by sensor with humidity and temperature
+	// codes and their verification result. Temperature taken
+	// from humidity measurement is sent without CRC, so
+	// TemperatureCRC is zero and not verified then.
+	HumidityCRC    byte `json:"humidity_crc"`
+	TemperatureCRC byte `json:"temperature_crc"`
+	CRCValid       bool `json:"crc_valid"`
+	// Acquisition start and end time.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Conversion latency: time from measurement
+	// command till result received.
+	Latency time.Duration `json:"latency"`
+}
+
+// ReadMeasurement measure relative humidity and temperature and
+// return complete measurement record. In case of CRC mismatch
+// (remaining after retries, if retry policy is set), function
+// return both measurement with CRCValid false and CRC error.
+func (v *Si7021) ReadMeasurement(bus Bus) (*Measurement, error) {
+	return v.ReadMeasurementCtx(context.Background(), bus)
+}
+
+// ReadMeasurementCtx is the same as ReadMeasurement, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadMeasurementCtx(ctx context.Context, bus Bus) (*Measurement, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Reading measurement...")
+	reg, err := v.readUserReg(ctx, bus)
+	if err != nil {
+		return nil, err
+	}
+	var level *HeaterLevel
+	// Heater level is known only for chips with heater register.
+	if v.capabilities().Has(CAP_HEATER_LEVEL) {
+		hl, err := v.readHeaterLevel(ctx, bus)
+		if err != nil {
+			return nil, err
+		}
+		level = &hl
+	}
+	ur := DecodeUserRegister(reg)
+	var m *Measurement
+	err = v.withRetry(ctx, "measurement", func() error {
+		// Record of the attempt is taken only when it
+		// is complete, so attempts are never mixed.
+		m = nil
+		a := &Measurement{Resolution: ur.Resolution,
+			HeaterEnabled: ur.HeaterEnabled, HeaterLevel: level}
+		a.Start = time.Now()
+		rh, crc, err := v.doMeasure(ctx, bus, measureHumidity)
+		if err != nil && !errors.Is(err, ErrCRCMismatch) {
+			return err
+		}
+		a.Latency = time.Since(a.Start)
+		temp, tempCRC, err2 := v.doMeasure(ctx, bus, measureTempFromPrevious)
+		if err2 != nil && !errors.Is(err2, ErrCRCMismatch) {
+			return err2
+		}
+		a.End = time.Now()
+		a.HumidityCode, a.TemperatureCode = rh, temp
+		a.HumidityCRC, a.TemperatureCRC = crc, tempCRC
+		a.CRCValid = err == nil && err2 == nil
+		m = a
+		if err != nil {
+			return err
+		}
+		return err2
+	})
+	if err != nil && (!errors.Is(err, ErrCRCMismatch) || m == nil) {
+		return nil, err
+	}
+	m.RelativeHumidity = RelativeHumidity(v.uncompHumidityToRelativeHumidity(m.HumidityCode))
+	m.Temperature = Temperature(v.uncompTemperatureToCelsius(m.TemperatureCode))
+	if spec, err2 := GetSpecification(v.specModel()); err2 == nil {
+		m.RelativeHumidityUncertainty, m.TemperatureUncertainty =
+			spec.Uncertainty(float32(m.RelativeHumidity), float32(m.Temperature), m.Resolution)
+	}
+	return m, err
+}
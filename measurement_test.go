@@ -0,0 +1,59 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMeasurementHeaterLevel(t *testing.T) {
+	tests := []struct {
+		model ChipModel
+		known bool
+	}{
+		{CHIP_SI7021, true},
+		{CHIP_HTU21D, false},
+		{CHIP_SHT21, false},
+	}
+	for _, test := range tests {
+		sensor := NewSi7021()
+		if err := sensor.SetChipModel(test.model); err != nil {
+			t.Fatal(err)
+		}
+		m, err := sensor.ReadMeasurement(newChipSimulator(t, test.model))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (m.HeaterLevel != nil) != test.known {
+			t.Errorf("%v: heater level %v, known %v expected", test.model, m.HeaterLevel, test.known)
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"heater_level"`) != test.known {
+			t.Errorf("%v: unexpected heater level in %s", test.model, data)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+
+	"github.com/d2r2/go-si7021/psychrometrics"
+)
+
+// ReadPsychrometrics measure relative humidity and temperature
+// and return them together with derived psychrometric quantities
+// (see psychrometrics package), calculated for barometric
+// pressure (hPa, zero for standard one).
+func (v *Si7021) ReadPsychrometrics(bus Bus, pressure float32) (*psychrometrics.Psychrometrics, error) {
+	return v.ReadPsychrometricsCtx(context.Background(), bus, pressure)
+}
+
+// ReadPsychrometricsCtx is the same as ReadPsychrometrics, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadPsychrometricsCtx(ctx context.Context, bus Bus, pressure float32) (*psychrometrics.Psychrometrics, error) {
+	rh, temp, err := v.ReadRelativeHumidityAndTemperatureCtx(ctx, bus)
+	if err != nil {
+		return nil, err
+	}
+	return psychrometrics.Calculate(temp, rh, pressure), nil
+}
@@ -0,0 +1,148 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+// Package psychrometrics calculate quantities derived from relative
+// humidity and temperature: dew point, frost point, absolute humidity,
+// mixing ratio, specific humidity, wet-bulb temperature, vapour
+// pressure deficit, heat index and humidex.
+package psychrometrics
+
+import "math"
+
+// STANDARD_PRESSURE is standard sea level barometric pressure (hPa).
+const STANDARD_PRESSURE = 1013.25
+
+// Psychrometrics keep quantities derived
+// from relative humidity and temperature.
+type Psychrometrics struct {
+	// Source values: temperature (*C), relative humidity (%)
+	// and barometric pressure (hPa) used in calculations.
+	Temperature      float32
+	RelativeHumidity float32
+	Pressure         float32
+	// Dew point (*C), temperature to which air should be
+	// cooled to become saturated with water vapour.
+	DewPoint float32
+	// Frost point (*C), the same as dew point, but over ice.
+	FrostPoint float32
+	// Absolute humidity (g/m3), mass of water vapour in volume of air.
+	AbsoluteHumidity float32
+	// Mixing ratio (g/kg), mass of water vapour per mass of dry air.
+	MixingRatio float32
+	// Specific humidity (g/kg), mass of water vapour per mass of moist air.
+	SpecificHumidity float32
+	// Wet-bulb temperature (*C).
+	WetBulbTemperature float32
+	// Water vapour partial pressure (hPa).
+	VaporPressure float32
+	// Saturation vapour pressure at temperature (hPa).
+	SaturationVaporPressure float32
+	// Vapour pressure deficit (hPa).
+	VaporPressureDeficit float32
+	// Heat index (*C), apparent temperature
+	// by NOAA (US National Weather Service).
+	HeatIndex float32
+	// Humidex (*C), apparent temperature by Environment Canada.
+	Humidex float32
+}
+
+// SaturationVaporPressure return saturation vapour pressure
+// over water (hPa) for temperature in celsius (Magnus formula).
+func SaturationVaporPressure(temp float64) float64 {
+	return 6.112 * math.Exp(17.62*temp/(243.12+temp))
+}
+
+// round32 round float amount to certain precision.
+func round32(value float32, precision int) float32 {
+	return float32(math.Round(float64(value)*math.Pow10(precision)) /
+		math.Pow10(precision))
+}
+
+// wetBulbTemperature solve psychrometric equation
+// e = es(Tw) - gamma * p * (T - Tw) with bisection.
+func wetBulbTemperature(temp, dewPoint, e, pressure float64) float64 {
+	const gamma = 0.000662
+	low, high := dewPoint, temp
+	for i := 0; i < 60; i++ {
+		tw := (low + high) / 2
+		if SaturationVaporPressure(tw)-gamma*pressure*(temp-tw) > e {
+			high = tw
+		} else {
+			low = tw
+		}
+	}
+	return (low + high) / 2
+}
+
+// heatIndex calculate heat index (*C) according to NOAA algorithm.
+func heatIndex(temp, rh float64) float64 {
+	t := temp*9/5 + 32
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh -
+			0.22475541*t*rh - 0.00683783*t*t -
+			0.05481717*rh*rh + 0.00122874*t*t*rh +
+			0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+		if rh < 13 && t >= 80 && t <= 112 {
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		} else if rh > 85 && t >= 80 && t <= 87 {
+			hi += (rh - 85) / 10 * (87 - t) / 5
+		}
+	}
+	return (hi - 32) * 5 / 9
+}
+
+// Calculate calculate quantities derived from temperature (*C)
+// and relative humidity (%). Barometric pressure (hPa) affect mixing
+// ratio, specific humidity and wet-bulb temperature; pass zero
+// to use STANDARD_PRESSURE. Sensor may report humidity above 100%
+// near saturation, so humidity is clamped to 0-100% range.
+func Calculate(temp, rh, pressure float32) *Psychrometrics {
+	if pressure <= 0 {
+		pressure = STANDARD_PRESSURE
+	}
+	t, p := float64(temp), float64(pressure)
+	// Keep humidity above zero to get finite dew point.
+	r := math.Min(math.Max(float64(rh), 0.01), 100)
+	es := SaturationVaporPressure(t)
+	e := r / 100 * es
+	gamma := math.Log(e / 6.112)
+	dewPoint := 243.12 * gamma / (17.62 - gamma)
+	frostPoint := 272.62 * gamma / (22.46 - gamma)
+	humidexE := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint)))
+	v := &Psychrometrics{
+		Temperature:             temp,
+		RelativeHumidity:        float32(math.Min(math.Max(float64(rh), 0), 100)),
+		Pressure:                pressure,
+		DewPoint:                round32(float32(dewPoint), 2),
+		FrostPoint:              round32(float32(frostPoint), 2),
+		AbsoluteHumidity:        round32(float32(216.7*e/(273.15+t)), 2),
+		MixingRatio:             round32(float32(621.97*e/(p-e)), 2),
+		SpecificHumidity:        round32(float32(621.97*e/(p-0.378*e)), 2),
+		WetBulbTemperature:      round32(float32(wetBulbTemperature(t, dewPoint, e, p)), 2),
+		VaporPressure:           round32(float32(e), 2),
+		SaturationVaporPressure: round32(float32(es), 2),
+		VaporPressureDeficit:    round32(float32(es-e), 2),
+		HeatIndex:               round32(float32(heatIndex(t, r)), 2),
+		Humidex:                 round32(float32(t+0.5555*(humidexE-10)), 2),
+	}
+	return v
+}
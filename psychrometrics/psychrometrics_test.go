@@ -0,0 +1,87 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package psychrometrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateReference(t *testing.T) {
+	// Reference values from psychrometric tables, NWS heat index
+	// table and Environment Canada humidex table, sea level pressure.
+	tests := []struct {
+		name      string
+		temp, rh  float32
+		actual    func(p *Psychrometrics) float32
+		reference float32
+		tolerance float32
+	}{
+		{"dew point", 25, 50, func(p *Psychrometrics) float32 { return p.DewPoint }, 13.9, 0.1},
+		{"dew point", 30, 80, func(p *Psychrometrics) float32 { return p.DewPoint }, 26.2, 0.1},
+		{"dew point", 20, 100, func(p *Psychrometrics) float32 { return p.DewPoint }, 20, 0.01},
+		{"wet-bulb", 25, 50, func(p *Psychrometrics) float32 { return p.WetBulbTemperature }, 17.9, 0.2},
+		{"wet-bulb", 20, 50, func(p *Psychrometrics) float32 { return p.WetBulbTemperature }, 13.8, 0.2},
+		{"wet-bulb", 20, 100, func(p *Psychrometrics) float32 { return p.WetBulbTemperature }, 20, 0.01},
+		// 90*F at 60% give 100*F, 100*F at 40% give 109*F.
+		{"heat index", 32.22, 60, func(p *Psychrometrics) float32 { return p.HeatIndex }, 37.78, 0.5},
+		{"heat index", 37.78, 40, func(p *Psychrometrics) float32 { return p.HeatIndex }, 42.78, 0.5},
+		// 30*C with dew point 15*C give humidex 34.
+		{"humidex", 30, 39.8, func(p *Psychrometrics) float32 { return p.Humidex }, 34, 0.5},
+		{"absolute humidity", 25, 50, func(p *Psychrometrics) float32 { return p.AbsoluteHumidity }, 11.5, 0.1},
+		{"mixing ratio", 25, 50, func(p *Psychrometrics) float32 { return p.MixingRatio }, 9.9, 0.1},
+		// Magnus formula is within 0.3% of tables.
+		{"saturation vapour pressure", 20, 50,
+			func(p *Psychrometrics) float32 { return p.SaturationVaporPressure }, 23.39, 0.1},
+	}
+	for _, test := range tests {
+		p := Calculate(test.temp, test.rh, 0)
+		if value := test.actual(p); math.Abs(float64(value-test.reference)) > float64(test.tolerance) {
+			t.Errorf("%s at %v*C, %v%%: %v, reference %v", test.name, test.temp, test.rh,
+				value, test.reference)
+		}
+	}
+}
+
+func TestCalculatePressure(t *testing.T) {
+	p := Calculate(25, 50, 0)
+	if p.Pressure != STANDARD_PRESSURE {
+		t.Errorf("pressure %v, expected standard one", p.Pressure)
+	}
+	// Less air at altitude carry the same vapour.
+	p2 := Calculate(25, 50, 850)
+	if p2.MixingRatio <= p.MixingRatio || p2.WetBulbTemperature >= p.WetBulbTemperature ||
+		p2.DewPoint != p.DewPoint {
+		t.Errorf("unexpected pressure dependence: %+v, %+v", p, p2)
+	}
+}
+
+func TestCalculateClamp(t *testing.T) {
+	p := Calculate(20, 110, 0)
+	if p.RelativeHumidity != 100 || p.DewPoint != 20 || p.VaporPressureDeficit != 0 {
+		t.Errorf("humidity above 100%% is not clamped: %+v", p)
+	}
+	p = Calculate(20, -5, 0)
+	if p.RelativeHumidity != 0 || math.IsInf(float64(p.DewPoint), 0) || math.IsNaN(float64(p.DewPoint)) {
+		t.Errorf("humidity below 0%% is not clamped: %+v", p)
+	}
+}
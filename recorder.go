@@ -0,0 +1,247 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TransactionKind denote direction of bus transaction.
+type TransactionKind string
+
+const (
+	TRANSACTION_WRITE TransactionKind = "write"
+	TRANSACTION_READ  TransactionKind = "read"
+)
+
+// HexBytes is a byte slice, which is
+// serialized to text as hex string.
+type HexBytes []byte
+
+// MarshalText implement encoding.TextMarshaler interface.
+func (v HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(v)), nil
+}
+
+// UnmarshalText implement encoding.TextUnmarshaler interface.
+func (v *HexBytes) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*v = b
+	return nil
+}
+
+// Transaction keep single bus operation
+// issued by driver: when it happens, direction,
+// payload and error (if any) returned by bus.
+type Transaction struct {
+	Time  time.Time       `json:"time"`
+	Kind  TransactionKind `json:"kind"`
+	Data  HexBytes        `json:"data"`
+	Error string          `json:"error,omitempty"`
+}
+
+// Recorder is a Bus wrapper, which pass all operations
+// to underlying bus and write them to transcript
+// (one JSON object per line), which could be served
+// back later with ReplayBus.
+type Recorder struct {
+	mutex sync.Mutex
+	bus   Bus
+	enc   *json.Encoder
+}
+
+// NewRecorder returns new Recorder, which forward
+// operations to bus and save them to w.
+func NewRecorder(bus Bus, w io.Writer) *Recorder {
+	v := &Recorder{bus: bus, enc: json.NewEncoder(w)}
+	return v
+}
+
+func (v *Recorder) record(kind TransactionKind, data []byte, err error) error {
+	tr := Transaction{Time: time.Now(), Kind: kind,
+		Data: append(HexBytes{}, data...)}
+	if err != nil {
+		tr.Error = err.Error()
+	}
+	return v.enc.Encode(&tr)
+}
+
+// WriteBytes implement Bus interface.
+func (v *Recorder) WriteBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	n, err := v.bus.WriteBytes(buf)
+	err2 := v.record(TRANSACTION_WRITE, buf, err)
+	if err != nil {
+		return n, err
+	}
+	return n, err2
+}
+
+// ReadBytes implement Bus interface.
+func (v *Recorder) ReadBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	n, err := v.bus.ReadBytes(buf)
+	err2 := v.record(TRANSACTION_READ, buf[:n], err)
+	if err != nil {
+		return n, err
+	}
+	return n, err2
+}
+
+// WriteReadBytes implement WriteReadBus interface.
+// Combined transaction is recorded as write followed by read.
+func (v *Recorder) WriteReadBytes(wbuf []byte, rbuf []byte) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if wrb, ok := v.bus.(WriteReadBus); ok {
+		err := wrb.WriteReadBytes(wbuf, rbuf)
+		err2 := v.record(TRANSACTION_WRITE, wbuf, nil)
+		if err2 == nil {
+			err2 = v.record(TRANSACTION_READ, rbuf, err)
+		}
+		if err != nil {
+			return err
+		}
+		return err2
+	}
+	_, err := v.bus.WriteBytes(wbuf)
+	err2 := v.record(TRANSACTION_WRITE, wbuf, err)
+	if err != nil {
+		return err
+	} else if err2 != nil {
+		return err2
+	}
+	n, err := v.bus.ReadBytes(rbuf)
+	err2 = v.record(TRANSACTION_READ, rbuf[:n], err)
+	if err != nil {
+		return err
+	}
+	return err2
+}
+
+// LoadTranscript read transactions saved by Recorder.
+func LoadTranscript(r io.Reader) ([]Transaction, error) {
+	var trs []Transaction
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var tr Transaction
+		err := json.Unmarshal(line, &tr)
+		if err != nil {
+			return nil, err
+		}
+		trs = append(trs, tr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return trs, nil
+}
+
+// ReplayBus is a Bus, which serve recorded transcript back.
+// Each write must match next recorded write exactly,
+// each read return next recorded read payload,
+// recorded bus errors are reproduced as well.
+type ReplayBus struct {
+	mutex        sync.Mutex
+	transactions []Transaction
+	index        int
+}
+
+// NewReplayBus returns new ReplayBus serving transactions.
+func NewReplayBus(transactions []Transaction) *ReplayBus {
+	v := &ReplayBus{transactions: transactions}
+	return v
+}
+
+// Remaining return number of transactions not replayed yet.
+func (v *ReplayBus) Remaining() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.transactions) - v.index
+}
+
+func (v *ReplayBus) next(kind TransactionKind) (*Transaction, error) {
+	if v.index >= len(v.transactions) {
+		return nil, errors.New(spew.Sprintf(
+			"Replay: transcript exhausted, while %s expected", kind))
+	}
+	tr := &v.transactions[v.index]
+	if tr.Kind != kind {
+		return nil, errors.New(spew.Sprintf(
+			"Replay: transaction #%d is %s, while %s expected",
+			v.index, tr.Kind, kind))
+	}
+	v.index++
+	return tr, nil
+}
+
+// WriteBytes implement Bus interface.
+func (v *ReplayBus) WriteBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	tr, err := v.next(TRANSACTION_WRITE)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(tr.Data, buf) {
+		return 0, errors.New(spew.Sprintf(
+			"Replay: transaction #%d write mismatch: recorded %v, actual %v",
+			v.index-1, []byte(tr.Data), buf))
+	}
+	if tr.Error != "" {
+		return 0, errors.New(tr.Error)
+	}
+	return len(buf), nil
+}
+
+// ReadBytes implement Bus interface.
+func (v *ReplayBus) ReadBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	tr, err := v.next(TRANSACTION_READ)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(buf, tr.Data)
+	if tr.Error != "" {
+		return n, errors.New(tr.Error)
+	}
+	return n, nil
+}
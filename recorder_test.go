@@ -0,0 +1,116 @@
+package si7021
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden transcripts in testdata")
+
+const goldenTranscript = "testdata/session.jsonl"
+
+// sessionResult keep values read by runSession.
+type sessionResult struct {
+	serialNumber int64
+	firmware     FirmwareVersion
+	rh, temp     float32
+	temp2        float32
+}
+
+// runSession issue fixed sequence of sensor commands.
+func runSession(t *testing.T, bus Bus) sessionResult {
+	t.Helper()
+	sensor := NewSi7021()
+	var res sessionResult
+	var err error
+	if err = sensor.Reset(bus); err != nil {
+		t.Fatal(err)
+	}
+	if res.serialNumber, err = sensor.ReadSerialNumber(bus); err != nil {
+		t.Fatal(err)
+	}
+	if res.firmware, err = sensor.ReadFirmwareVersion(bus); err != nil {
+		t.Fatal(err)
+	}
+	if res.rh, res.temp, err = sensor.ReadRelativeHumidityAndTemperature(bus); err != nil {
+		t.Fatal(err)
+	}
+	if err = sensor.SetMeasureResolution(bus, RES_RH_8BIT_TEMP_12BIT); err != nil {
+		t.Fatal(err)
+	}
+	if res.temp2, err = sensor.ReadTemperature(bus); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func newSessionSimulator() *Simulator {
+	sim := NewSimulator()
+	sim.SetSerialNumber(0x0102030415AABBCC)
+	sim.SetAmbient(23.5, 41.2)
+	return sim
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	live := runSession(t, NewRecorder(newSessionSimulator(), &buf))
+	trs, err := LoadTranscript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewReplayBus(trs)
+	replayed := runSession(t, replay)
+	if replayed != live {
+		t.Errorf("replayed session %+v differ from live one %+v", replayed, live)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("%d transactions are not replayed", n)
+	}
+}
+
+func TestReplayGolden(t *testing.T) {
+	if *updateGolden {
+		var buf bytes.Buffer
+		runSession(t, NewRecorder(newSessionSimulator(), &buf))
+		if err := os.WriteFile(goldenTranscript, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(goldenTranscript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	trs, err := LoadTranscript(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewReplayBus(trs)
+	res := runSession(t, replay)
+	// Values quantized at 12/14 bit and then at 8/12 bit resolution.
+	expected := sessionResult{serialNumber: 0x0102030415AABBCC,
+		firmware: FIRMWARE_VER_2_0, rh: 41.18, temp: 23.5, temp2: 23.46}
+	if res != expected {
+		t.Errorf("replayed session %+v, expected %+v", res, expected)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("%d transactions are not replayed", n)
+	}
+}
+
+func TestReplayWriteMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	sensor := NewSi7021()
+	if _, err := sensor.ReadFirmwareVersion(NewRecorder(newSessionSimulator(), &buf)); err != nil {
+		t.Fatal(err)
+	}
+	trs, err := LoadTranscript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sensor.ReadSerialNumber(NewReplayBus(trs)); err == nil {
+		t.Error("expected error on command not matching transcript")
+	}
+}
@@ -0,0 +1,127 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy define how driver repeat measurements
+// and register reads failed with transient errors,
+// like single CRC mismatch or EREMOTEIO on long wires.
+type RetryPolicy struct {
+	// MaxAttempts is total number of attempts,
+	// including first one. Value less than 2
+	// disable retries.
+	MaxAttempts int
+	// Backoff is pause before second attempt.
+	Backoff time.Duration
+	// BackoffFactor multiply pause before each next
+	// attempt. Value less than 1 keep pause constant.
+	BackoffFactor float64
+	// Retryable list error classes (ErrCRCMismatch, ErrBusIO,
+	// ErrConversionTimeout), which should be retried.
+	// When empty, ErrCRCMismatch and ErrBusIO are retried.
+	Retryable []error
+	// OnRetry, if defined, is called before each repeated
+	// attempt with attempt number (starting from 2),
+	// operation name and error caused retry.
+	// Callback run while sensor is locked, so it
+	// must not call sensor methods.
+	OnRetry func(attempt int, op string, err error)
+}
+
+// NewRetryPolicy returns retry policy with maxAttempts
+// attempts, constant backoff and default retryable errors.
+func NewRetryPolicy(maxAttempts int, backoff time.Duration) *RetryPolicy {
+	v := &RetryPolicy{MaxAttempts: maxAttempts, Backoff: backoff}
+	return v
+}
+
+// IsRetryable verify that error belong to retryable classes.
+// Context cancellation is never retryable.
+func (v *RetryPolicy) IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	retryable := v.Retryable
+	if len(retryable) == 0 {
+		retryable = []error{ErrCRCMismatch, ErrBusIO}
+	}
+	for _, item := range retryable {
+		// Timeout wrap bus error of last poll, but it is
+		// retried only when ErrConversionTimeout is listed.
+		if errors.Is(err, ErrConversionTimeout) {
+			if item == ErrConversionTimeout {
+				return true
+			}
+		} else if errors.Is(err, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetryPolicy define retry policy for measurements
+// and register reads. Pass nil to disable retries.
+func (v *Si7021) SetRetryPolicy(policy *RetryPolicy) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.retryPolicy = policy
+}
+
+// GetRetryPolicy return retry policy in use, or nil.
+func (v *Si7021) GetRetryPolicy() *RetryPolicy {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.retryPolicy
+}
+
+// withRetry run operation f, repeating it according to
+// retry policy, while it fail with retryable error.
+func (v *Si7021) withRetry(ctx context.Context, op string, f func() error) error {
+	policy := v.retryPolicy
+	err := f()
+	if policy == nil {
+		return err
+	}
+	backoff := policy.Backoff
+	for attempt := 2; attempt <= policy.MaxAttempts && policy.IsRetryable(err); attempt++ {
+		lg.Warnf("Retrying %s (attempt %d of %d) after error: %v",
+			op, attempt, policy.MaxAttempts, err)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, op, err)
+		}
+		err2 := sleepContext(ctx, backoff)
+		if err2 != nil {
+			return err2
+		}
+		if policy.BackoffFactor > 1 {
+			backoff = time.Duration(float64(backoff) * policy.BackoffFactor)
+		}
+		err = f()
+	}
+	return err
+}
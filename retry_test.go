@@ -0,0 +1,112 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"errors"
+	"testing"
+)
+
+// flakyBus pass operations to simulator, but fail
+// first failures writes, like long wires do.
+type flakyBus struct {
+	sim      *Simulator
+	failures int
+}
+
+func (v *flakyBus) WriteBytes(buf []byte) (int, error) {
+	if v.failures > 0 {
+		v.failures--
+		return 0, errors.New("remote I/O error")
+	}
+	return v.sim.WriteBytes(buf)
+}
+
+func (v *flakyBus) ReadBytes(buf []byte) (int, error) {
+	return v.sim.ReadBytes(buf)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *RetryPolicy
+		failures  int
+		retries   int
+		expectErr bool
+	}{
+		{"no policy", nil, 1, 0, true},
+		{"recovered", NewRetryPolicy(3, 0), 2, 2, false},
+		{"exhausted", NewRetryPolicy(2, 0), 2, 1, true},
+		{"not retryable", &RetryPolicy{MaxAttempts: 3,
+			Retryable: []error{ErrCRCMismatch}}, 1, 0, true},
+	}
+	for _, test := range tests {
+		var attempts []int
+		if test.policy != nil {
+			test.policy.OnRetry = func(attempt int, op string, err error) {
+				if !errors.Is(err, ErrBusIO) {
+					t.Errorf("%s: retry caused by %v", test.name, err)
+				}
+				attempts = append(attempts, attempt)
+			}
+		}
+		sensor := NewSi7021()
+		sensor.SetRetryPolicy(test.policy)
+		_, err := sensor.ReadRelativeHumidity(&flakyBus{sim: NewSimulator(), failures: test.failures})
+		if test.expectErr {
+			var busErr *BusError
+			if !errors.Is(err, ErrBusIO) || !errors.As(err, &busErr) {
+				t.Errorf("%s: expected bus error, got %v", test.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if len(attempts) != test.retries {
+			t.Errorf("%s: %d retries, expected %d", test.name, len(attempts), test.retries)
+		}
+		for i, attempt := range attempts {
+			if attempt != i+2 {
+				t.Errorf("%s: retry attempt numbers %v", test.name, attempts)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryCRCMismatch(t *testing.T) {
+	sensor := NewSi7021()
+	var retries int
+	policy := NewRetryPolicy(3, 0)
+	policy.OnRetry = func(attempt int, op string, err error) {
+		retries++
+	}
+	sensor.SetRetryPolicy(policy)
+	// CRC byte of every humidity result is corrupted.
+	bus := &corruptBus{sim: NewSimulator(), cmd: CMD_REL_HUM, index: 2}
+	_, err := sensor.ReadRelativeHumidity(bus)
+	if !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected CRC mismatch, got %v", err)
+	}
+	if retries != 2 {
+		t.Errorf("%d retries, expected 2", retries)
+	}
+}
@@ -0,0 +1,212 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/d2r2/go-si7021/psychrometrics"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// SelfHeatingModel is a first order thermal model of sensor
+// heated by internal heater: temperature rise is proportional
+// to heater current and approach steady state exponentially.
+// Parameters are specific to device and board, so they should
+// be learned with HeaterController.CalibrateSelfHeating.
+type SelfHeatingModel struct {
+	// Steady state temperature rise
+	// per 1 mA of heater current (*C/mA).
+	ThermalGain float64
+	// Thermal time constant of sensor.
+	TimeConstant time.Duration
+}
+
+// TemperatureRise estimate how much (*C) sensor is warmer
+// than ambient environment after heater was on at level
+// for elapsed time.
+func (v *SelfHeatingModel) TemperatureRise(level HeaterLevel, elapsed time.Duration) float64 {
+	if elapsed <= 0 || v.TimeConstant <= 0 {
+		return 0
+	}
+	x := 1 - math.Exp(-float64(elapsed)/float64(v.TimeConstant))
+	return v.ThermalGain * heaterCurrent(level) * x
+}
+
+// Compensate estimate ambient temperature and relative
+// humidity from values measured by sensor, while heater is on
+// at level for elapsed time. Humidity is recalculated
+// assuming that vapour pressure is the same near heated
+// sensor and in ambient environment.
+func (v *SelfHeatingModel) Compensate(temp, rh float32, level HeaterLevel,
+	elapsed time.Duration) (float32, float32) {
+
+	rise := v.TemperatureRise(level, elapsed)
+	ambientTemp := float64(temp) - rise
+	ambientRH := float64(rh) * psychrometrics.SaturationVaporPressure(float64(temp)) /
+		psychrometrics.SaturationVaporPressure(ambientTemp)
+	ambientRH = math.Max(0, math.Min(100, ambientRH))
+	return round32(float32(ambientTemp), 2), round32(float32(ambientRH), 2)
+}
+
+// ReadCompensatedCtx measure relative humidity and temperature,
+// and if heater is on now, estimate ambient values with model.
+func (v *HeaterController) ReadCompensatedCtx(ctx context.Context,
+	model *SelfHeatingModel) (float32, float32, error) {
+
+	if model == nil {
+		return 0, 0, errors.New("Self-heating model is not defined")
+	}
+	rh, temp, err := v.sensor.ReadRelativeHumidityAndTemperatureCtx(ctx, v.bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	heating, level, elapsed := v.GetHeatingState()
+	if heating {
+		temp, rh = model.Compensate(temp, rh, level, elapsed)
+	}
+	return rh, temp, nil
+}
+
+// fitSelfHeating find steady state rise and time constant of exponential
+// curve rise*(1-exp(-t/tau)) approximating samples with least squares.
+func fitSelfHeating(times []time.Duration, rises []float64,
+	minTau, maxTau time.Duration) (float64, time.Duration) {
+
+	const steps = 400
+	bestErr := math.Inf(1)
+	var bestRise float64
+	var bestTau time.Duration
+	ratio := math.Pow(float64(maxTau)/float64(minTau), 1.0/steps)
+	for i := 0; i <= steps; i++ {
+		tau := float64(minTau) * math.Pow(ratio, float64(i))
+		var sxy, sxx float64
+		xs := make([]float64, len(times))
+		for j, t := range times {
+			xs[j] = 1 - math.Exp(-float64(t)/tau)
+			sxy += xs[j] * rises[j]
+			sxx += xs[j] * xs[j]
+		}
+		if sxx == 0 {
+			continue
+		}
+		rise := sxy / sxx
+		var sse float64
+		for j := range xs {
+			d := rise*xs[j] - rises[j]
+			sse += d * d
+		}
+		if sse < bestErr {
+			bestErr, bestRise, bestTau = sse, rise, time.Duration(tau)
+		}
+	}
+	return bestRise, bestTau
+}
+
+// CalibrateSelfHeating learn self-heating model of sensor: measure
+// ambient temperature, run heating pulse at level for duration,
+// sampling temperature with interval, and fit thermal
+// model to samples. Ambient temperature should be stable
+// during calibration; duration should be a few thermal time
+// constants long (tens of seconds) for accurate result.
+func (v *HeaterController) CalibrateSelfHeating(ctx context.Context, level HeaterLevel,
+	duration, interval time.Duration) (*SelfHeatingModel, error) {
+
+	if duration > v.GetMaxOnTime() {
+		return nil, errors.New(spew.Sprintf(
+			"Calibration duration %v exceed heater maximum on-time %v",
+			duration, v.GetMaxOnTime()))
+	}
+	if interval <= 0 || interval*2 > duration {
+		return nil, errors.New(spew.Sprintf(
+			"Calibration sampling interval %v is not suitable for duration %v",
+			interval, duration))
+	}
+	ambient, err := v.sensor.ReadTemperatureCtx(ctx, v.bus)
+	if err != nil {
+		return nil, err
+	}
+	lg.Debugf("Self-heating calibration: ambient temperature %v*C", ambient)
+
+	ctx2, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var pulseErr error
+	start := time.Now()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pulseErr = v.Pulse(ctx2, level, duration)
+	}()
+	var times []time.Duration
+	var rises []float64
+	// Chips without heater level register heat
+	// at other level, than requested one.
+	applied := level
+	for {
+		err = sleepContext(ctx2, interval)
+		if err != nil {
+			break
+		}
+		elapsed := time.Since(start)
+		if elapsed >= duration {
+			break
+		}
+		temp, err2 := v.sensor.ReadTemperatureCtx(ctx2, v.bus)
+		if err2 != nil {
+			err = err2
+			break
+		}
+		if heating, hl, _ := v.GetHeatingState(); heating {
+			applied = hl
+		}
+		times = append(times, elapsed)
+		rises = append(rises, float64(temp-ambient))
+	}
+	if err != nil {
+		// Stop heating earlier.
+		cancel()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if pulseErr != nil {
+		return nil, pulseErr
+	} else if err != nil {
+		return nil, err
+	}
+	if len(times) < 2 {
+		return nil, errors.New("Not enough samples taken for self-heating calibration")
+	}
+	rise, tau := fitSelfHeating(times, rises, interval/10, duration*10)
+	model := &SelfHeatingModel{
+		ThermalGain:  rise / heaterCurrent(applied),
+		TimeConstant: tau,
+	}
+	lg.Debugf("Self-heating calibration: steady rise %.2f*C at %v, time constant %v",
+		rise, applied, tau)
+	return model, nil
+}
@@ -0,0 +1,74 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalibrateSelfHeatingAppliedLevel(t *testing.T) {
+	for _, model := range []ChipModel{CHIP_SI7021, CHIP_HTU21D} {
+		sim := NewSimulator()
+		if err := sim.SetChipModel(model); err != nil {
+			t.Fatal(err)
+		}
+		actual := &SelfHeatingModel{ThermalGain: 0.5, TimeConstant: 150 * time.Millisecond}
+		sim.SetSelfHeating(actual)
+		sensor := NewSi7021()
+		if err := sensor.SetChipModel(model); err != nil {
+			t.Fatal(err)
+		}
+		heater := NewHeaterController(sensor, sim, 0)
+		learned, err := heater.CalibrateSelfHeating(context.Background(),
+			HEATER_LEVEL_5, time.Second, 50*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(learned.ThermalGain-actual.ThermalGain) > 0.05 {
+			t.Errorf("%v: thermal gain %.3f, want %.3f", model, learned.ThermalGain, actual.ThermalGain)
+		}
+	}
+}
+
+func TestReadCompensatedNilModel(t *testing.T) {
+	sim := NewSimulator()
+	heater := NewHeaterController(NewSi7021(), sim, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- heater.Pulse(ctx, HEATER_LEVEL_1, time.Second)
+	}()
+	for {
+		if heating, _, _ := heater.GetHeatingState(); heating {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, err := heater.ReadCompensatedCtx(context.Background(), nil); err == nil {
+		t.Error("expected error for undefined model")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,407 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Si7013 I2C addresses selected by AD0 pin.
+const (
+	SI7013_ADDRESS_AD0_LOW  byte = 0x40
+	SI7013_ADDRESS_AD0_HIGH byte = 0x41
+)
+
+// VoltageReference define reference voltage (VREFP)
+// used by Si7013 analog input.
+type VoltageReference byte
+
+const (
+	VREF_INTERNAL_1_25V VoltageReference = 0x00 // Internal 1.25V reference
+	VREF_VDDA           VoltageReference = 0x06 // VDDA supply used as reference
+	VREF_MASK           VoltageReference = 0x06
+)
+
+// SI7013_INTERNAL_VREF is a voltage of internal reference.
+const SI7013_INTERNAL_VREF = 1.25
+
+// String define stringer interface.
+func (v VoltageReference) String() string {
+	switch v & VREF_MASK {
+	case VREF_INTERNAL_1_25V:
+		return "Internal 1.25V"
+	case VREF_VDDA:
+		return "VDDA"
+	default:
+		return spew.Sprintf("Reserved (0x%02X)", byte(v&VREF_MASK))
+	}
+}
+
+// Bits of Si7013 user register 2.
+const (
+	USER_REG2_VOUT          byte = 0x01 // VOUT pin connected to VDDD (otherwise to GND)
+	USER_REG2_VIN_BUFFERED  byte = 0x10 // VIN input buffered
+	USER_REG2_THERMIST_CORR byte = 0x20 // Apply thermistor correction to analog result
+	USER_REG2_NO_HOLD       byte = 0x40 // Analog conversion made in No Hold Master Mode
+	USER_REG2_RESERVED_MASK byte = 0x88 // Reserved bits (D7, D3), kept unchanged on write
+)
+
+// UserRegister2 is decoded content of Si7013 user register 2
+// (voltage measurement setup).
+type UserRegister2 struct {
+	// VOUT pin driven high (to VDDD), otherwise low.
+	VOutHigh bool
+	// Reference voltage for analog input.
+	VRef VoltageReference
+	// VIN input buffered.
+	VInBuffered bool
+	// Thermistor correction applied to analog result.
+	ThermistorCorrection bool
+	// Analog conversion made in No Hold Master Mode.
+	NoHold bool
+	// Reserved bits as read from sensor.
+	Reserved byte
+}
+
+// DecodeUserRegister2 decode raw user register 2 value.
+func DecodeUserRegister2(reg byte) *UserRegister2 {
+	v := &UserRegister2{
+		VOutHigh:             reg&USER_REG2_VOUT != 0,
+		VRef:                 VoltageReference(reg) & VREF_MASK,
+		VInBuffered:          reg&USER_REG2_VIN_BUFFERED != 0,
+		ThermistorCorrection: reg&USER_REG2_THERMIST_CORR != 0,
+		NoHold:               reg&USER_REG2_NO_HOLD != 0,
+		Reserved:             reg & USER_REG2_RESERVED_MASK,
+	}
+	return v
+}
+
+// Encode return raw user register 2 value to write to sensor.
+func (v *UserRegister2) Encode() byte {
+	reg := byte(v.VRef&VREF_MASK) | v.Reserved&USER_REG2_RESERVED_MASK
+	if v.VOutHigh {
+		reg |= USER_REG2_VOUT
+	}
+	if v.VInBuffered {
+		reg |= USER_REG2_VIN_BUFFERED
+	}
+	if v.ThermistorCorrection {
+		reg |= USER_REG2_THERMIST_CORR
+	}
+	if v.NoHold {
+		reg |= USER_REG2_NO_HOLD
+	}
+	return reg
+}
+
+// String define stringer interface.
+func (v *UserRegister2) String() string {
+	return spew.Sprintf("VOUT high: %v, VREF: %v, VIN buffered: %v, "+
+		"Thermistor correction: %v, No hold: %v, Reserved: 0x%02X",
+		v.VOutHigh, v.VRef, v.VInBuffered, v.ThermistorCorrection, v.NoHold, v.Reserved)
+}
+
+// ThermistorCoefficient is a single byte of Si7013
+// thermistor correction table located at Address.
+// Table layout and values are described in Si7013 datasheet.
+type ThermistorCoefficient struct {
+	Address byte
+	Value   byte
+}
+
+// Si7013 extend Si7021 driver with Si7013 specific
+// features: user register 2, analog (VIN) input
+// and thermistor correction. Humidity, temperature
+// and heater functions are inherited from Si7021.
+type Si7013 struct {
+	*Si7021
+}
+
+// NewSi7013 returns new Si7013 sensor instance.
+func NewSi7013() *Si7013 {
+	v := &Si7013{Si7021: NewSi7021()}
+	v.variant = chipVariants[CHIP_SI7013]
+	return v
+}
+
+func (v *Si7013) readUserReg2(ctx context.Context, bus Bus) (byte, error) {
+	err := v.checkSupported("user register 2", CMD_READ_USER_REG_2)
+	if err != nil {
+		return 0, err
+	}
+	buf1 := make([]byte, 1)
+	err = v.withRetry(ctx, "user register 2 read", func() error {
+		return writeReadBytes(ctx, bus, CMD_READ_USER_REG_2, buf1)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return buf1[0], nil
+}
+
+// ReadUserRegister2 read and decode user register 2.
+func (v *Si7013) ReadUserRegister2(bus Bus) (*UserRegister2, error) {
+	return v.ReadUserRegister2Ctx(context.Background(), bus)
+}
+
+// ReadUserRegister2Ctx is the same as ReadUserRegister2, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) ReadUserRegister2Ctx(ctx context.Context, bus Bus) (*UserRegister2, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	reg, err := v.readUserReg2(ctx, bus)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeUserRegister2(reg), nil
+}
+
+// UpdateUserRegister2 make read-modify-write of user register 2
+// keeping reserved bits intact and verify it with read-back.
+func (v *Si7013) UpdateUserRegister2(bus Bus, update func(ur *UserRegister2)) error {
+	return v.UpdateUserRegister2Ctx(context.Background(), bus, update)
+}
+
+// UpdateUserRegister2Ctx is the same as UpdateUserRegister2, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) UpdateUserRegister2Ctx(ctx context.Context, bus Bus, update func(ur *UserRegister2)) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Updating user register 2...")
+	reg, err := v.readUserReg2(ctx, bus)
+	if err != nil {
+		return err
+	}
+	ur := DecodeUserRegister2(reg)
+	update(ur)
+	// Never touch reserved bits.
+	ur.Reserved = reg & USER_REG2_RESERVED_MASK
+	reg = ur.Encode()
+	err = writeBytes(ctx, bus, append(CMD_WRITE_USER_REG_2, reg))
+	if err != nil {
+		return err
+	}
+	reg2, err := v.readUserReg2(ctx, bus)
+	if err != nil {
+		return err
+	}
+	if reg2 != reg {
+		return &VerifyError{Register: "user register 2",
+			Written: reg, ReadBack: reg2}
+	}
+	return nil
+}
+
+// doMeasureAnalog run analog input conversion. Hold or No Hold
+// Master Mode is defined by NO_HOLD bit of user register 2,
+// in No Hold Master Mode result is polled.
+func (v *Si7013) doMeasureAnalog(ctx context.Context, bus Bus) (uint16, *UserRegister2, error) {
+	const dataBytesCount = 2
+	const crcBytesCount = 1
+	err := v.checkSupported("analog input", CMD_MEASURE_ANALOG)
+	if err != nil {
+		return 0, nil, err
+	}
+	reg, err := v.readUserReg2(ctx, bus)
+	if err != nil {
+		return 0, nil, err
+	}
+	ur := DecodeUserRegister2(reg)
+	buf := make([]byte, dataBytesCount+crcBytesCount)
+	if ur.NoHold {
+		err = writeBytes(ctx, bus, CMD_MEASURE_ANALOG)
+		if err != nil {
+			return 0, nil, err
+		}
+		err = v.pollResult(ctx, bus, buf)
+	} else {
+		err = writeReadBytes(ctx, bus, CMD_MEASURE_ANALOG, buf)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	crc := buf[dataBytesCount]
+	calcCRC := calcCRC_SI7021(0x0, buf[:dataBytesCount])
+	if crc != calcCRC {
+		err := &CRCError{Field: "analog", Expected: calcCRC, Actual: crc}
+		return getU16BE(buf[:dataBytesCount]), ur, err
+	}
+	return getU16BE(buf[:dataBytesCount]), ur, nil
+}
+
+// ReadUncompAnalog returns raw 16-bit result of analog
+// input conversion (with thermistor correction applied,
+// if enabled in user register 2).
+func (v *Si7013) ReadUncompAnalog(bus Bus) (uint16, error) {
+	return v.ReadUncompAnalogCtx(context.Background(), bus)
+}
+
+// ReadUncompAnalogCtx is the same as ReadUncompAnalog, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) ReadUncompAnalogCtx(ctx context.Context, bus Bus) (uint16, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Reading analog input...")
+	var code uint16
+	err := v.withRetry(ctx, "analog measurement", func() error {
+		var err error
+		code, _, err = v.doMeasureAnalog(ctx, bus)
+		return err
+	})
+	return code, err
+}
+
+// UncompAnalogToVoltage convert analog conversion result
+// to voltage: result is a signed 16-bit fraction of reference
+// voltage vref, so full scale correspond to vref.
+func UncompAnalogToVoltage(code uint16, vref float32) float32 {
+	return float32(int16(code)) * vref / 32768
+}
+
+// ReadAnalogVoltage measure voltage on VIN input. Thermistor
+// correction must be disabled in user register 2. Parameter vdda
+// is used only, when VDDA is selected as reference voltage.
+func (v *Si7013) ReadAnalogVoltage(bus Bus, vdda float32) (float32, error) {
+	return v.ReadAnalogVoltageCtx(context.Background(), bus, vdda)
+}
+
+// ReadAnalogVoltageCtx is the same as ReadAnalogVoltage, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) ReadAnalogVoltageCtx(ctx context.Context, bus Bus, vdda float32) (float32, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Reading analog voltage...")
+	var code uint16
+	var ur *UserRegister2
+	err := v.withRetry(ctx, "analog measurement", func() error {
+		var err error
+		code, ur, err = v.doMeasureAnalog(ctx, bus)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if ur.ThermistorCorrection {
+		return 0, errors.New("Thermistor correction is enabled, result is not a voltage")
+	}
+	vref := float32(SI7013_INTERNAL_VREF)
+	if ur.VRef == VREF_VDDA {
+		vref = vdda
+	}
+	return UncompAnalogToVoltage(code, vref), nil
+}
+
+// ReadThermistorTemperature measure temperature of external
+// thermistor connected to analog input. Thermistor correction
+// must be enabled in user register 2 and correction table
+// loaded to sensor, so corrected result has the same scale
+// as temperature measured by sensor itself.
+func (v *Si7013) ReadThermistorTemperature(bus Bus) (float32, error) {
+	return v.ReadThermistorTemperatureCtx(context.Background(), bus)
+}
+
+// ReadThermistorTemperatureCtx is the same as ReadThermistorTemperature, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) ReadThermistorTemperatureCtx(ctx context.Context, bus Bus) (float32, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Reading thermistor temperature...")
+	var code uint16
+	var ur *UserRegister2
+	err := v.withRetry(ctx, "analog measurement", func() error {
+		var err error
+		code, ur, err = v.doMeasureAnalog(ctx, bus)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !ur.ThermistorCorrection {
+		return 0, errors.New("Thermistor correction is disabled, result is not a temperature")
+	}
+	return v.uncompTemperatureToCelsius(code), nil
+}
+
+func (v *Si7013) readThermistorCoefficient(ctx context.Context, bus Bus, addr byte) (byte, error) {
+	err := v.checkSupported("thermistor correction", CMD_READ_THERMIST_COEF)
+	if err != nil {
+		return 0, err
+	}
+	buf1 := make([]byte, 1)
+	err = v.withRetry(ctx, "thermistor coefficient read", func() error {
+		return writeReadBytes(ctx, bus, append(CMD_READ_THERMIST_COEF, addr), buf1)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return buf1[0], nil
+}
+
+// ReadThermistorCoefficient read thermistor correction
+// table byte located at address addr.
+func (v *Si7013) ReadThermistorCoefficient(bus Bus, addr byte) (byte, error) {
+	return v.ReadThermistorCoefficientCtx(context.Background(), bus, addr)
+}
+
+// ReadThermistorCoefficientCtx is the same as ReadThermistorCoefficient, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) ReadThermistorCoefficientCtx(ctx context.Context, bus Bus, addr byte) (byte, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.readThermistorCoefficient(ctx, bus, addr)
+}
+
+// WriteThermistorCoefficients load thermistor correction
+// table to sensor, verifying each byte with read-back.
+func (v *Si7013) WriteThermistorCoefficients(bus Bus, coeffs []ThermistorCoefficient) error {
+	return v.WriteThermistorCoefficientsCtx(context.Background(), bus, coeffs)
+}
+
+// WriteThermistorCoefficientsCtx is the same as WriteThermistorCoefficients, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7013) WriteThermistorCoefficientsCtx(ctx context.Context, bus Bus, coeffs []ThermistorCoefficient) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debugf("Writing %d thermistor coefficients...", len(coeffs))
+	err := v.checkSupported("thermistor correction", CMD_WRITE_THERMIST_COEF)
+	if err != nil {
+		return err
+	}
+	for _, item := range coeffs {
+		err := writeBytes(ctx, bus, append(CMD_WRITE_THERMIST_COEF, item.Address, item.Value))
+		if err != nil {
+			return err
+		}
+		b, err := v.readThermistorCoefficient(ctx, bus, item.Address)
+		if err != nil {
+			return err
+		}
+		if b != item.Value {
+			return &VerifyError{Register: spew.Sprintf("thermistor coefficient 0x%02X", item.Address),
+				Written: item.Value, ReadBack: b}
+		}
+	}
+	return nil
+}
@@ -23,11 +23,11 @@ package si7021
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
-	"errors"
+	"sync"
 	"time"
 
-	i2c "github.com/d2r2/go-i2c"
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -46,6 +46,12 @@ var (
 	CMD_READ_ID_1ST_PART   = []byte{0xFA, 0x0F} // Read Electronic ID 1st Byte
 	CMD_READ_ID_2ND_PART   = []byte{0xFC, 0xC9} // Read Electronic ID 2nd Byte
 	CMD_READ_FIRMWARE_REV  = []byte{0x84, 0xB8} // Read Firmware Revision
+	// Si7013 only commands.
+	CMD_MEASURE_ANALOG      = []byte{0xEE} // Measure Analog Voltage or Thermistor Temperature
+	CMD_WRITE_USER_REG_2    = []byte{0x50} // Write Voltage Measurement Setup (User Register 2)
+	CMD_READ_USER_REG_2     = []byte{0x10} // Read Voltage Measurement Setup (User Register 2)
+	CMD_WRITE_THERMIST_COEF = []byte{0xC5} // Write Thermistor Correction Coefficient
+	CMD_READ_THERMIST_COEF  = []byte{0x84} // Read Thermistor Correction Coefficient
 )
 
 type FirmwareVersion byte
@@ -73,6 +79,7 @@ type SensorType byte
 const (
 	SI_ENGINEERING_TYPE1 SensorType = 0x00
 	SI_ENGINEERING_TYPE2 SensorType = 0xFF
+	SI_7006_TYPE         SensorType = 0x06
 	SI_7013_TYPE         SensorType = 0x0D
 	SI_7020_TYPE         SensorType = 0x14
 	SI_7021_TYPE         SensorType = 0x15
@@ -83,6 +90,8 @@ func (v SensorType) String() string {
 	switch v {
 	case SI_ENGINEERING_TYPE1, SI_ENGINEERING_TYPE2:
 		return "engineering samples"
+	case SI_7006_TYPE:
+		return "Si7006"
 	case SI_7013_TYPE:
 		return "Si7013"
 	case SI_7020_TYPE:
@@ -165,24 +174,142 @@ func (v HeaterLevel) String() string {
 	return spew.Sprintf("HEATER_LEVEL_%d", v+1)
 }
 
+// MeasureMode define how driver wait
+// for measurement conversion completion.
+type MeasureMode int
+
+const (
+	// No Hold Master Mode: driver wait for maximum
+	// conversion time, then read result (default).
+	MEASURE_NO_HOLD MeasureMode = iota
+	// Hold Master Mode: sensor stretch clock until conversion
+	// complete, so read block without extra waiting.
+	// Use it only when i2c-bus master support clock stretching.
+	MEASURE_HOLD_MASTER
+	// No Hold Master Mode with polling: sensor NACK read
+	// requests until conversion complete, so driver repeat
+	// reading with poll interval until success or timeout.
+	MEASURE_NO_HOLD_POLLING
+)
+
+// String define stringer interface.
+func (v MeasureMode) String() string {
+	switch v {
+	case MEASURE_NO_HOLD:
+		return "No Hold Master Mode"
+	case MEASURE_HOLD_MASTER:
+		return "Hold Master Mode"
+	case MEASURE_NO_HOLD_POLLING:
+		return "No Hold Master Mode with polling"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Default polling parameters for MEASURE_NO_HOLD_POLLING mode.
+const (
+	DEFAULT_POLL_INTERVAL = time.Millisecond * 2
+	DEFAULT_POLL_TIMEOUT  = time.Millisecond * 100
+)
+
+// Si7021 is a sensor driver. Instance is safe for
+// concurrent use: bus command sequences and cached
+// sensor state are serialized with internal mutex.
 type Si7021 struct {
-	lastUserReg *byte
+	// Serialize bus sequences and access to fields below.
+	mutex sync.Mutex
+	// Resolution sensor configured to, which
+	// define measurement conversion time.
+	resolution   UserRegFlag
+	measureMode  MeasureMode
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+	retryPolicy  *RetryPolicy
+	// Cached sensor identity.
+	deviceInfo *DeviceInfo
+	// Chip specific commands and timings.
+	variant *ChipVariant
 }
 
 // NewSi7021 returns new sensor instance.
 func NewSi7021() *Si7021 {
-	v := &Si7021{}
+	v := &Si7021{
+		pollInterval: DEFAULT_POLL_INTERVAL,
+		pollTimeout:  DEFAULT_POLL_TIMEOUT,
+		variant:      chipVariants[CHIP_SI7021],
+	}
 	return v
 }
 
+// SetMeasureMode define how driver wait for measurement results.
+func (v *Si7021) SetMeasureMode(mode MeasureMode) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.measureMode = mode
+}
+
+// GetMeasureMode return measure mode in use.
+func (v *Si7021) GetMeasureMode() MeasureMode {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.measureMode
+}
+
+// SetPolling define how often driver try to read
+// measurement result in MEASURE_NO_HOLD_POLLING mode,
+// and how long to wait in total before report timeout.
+// Non-positive interval or timeout select
+// DEFAULT_POLL_INTERVAL or DEFAULT_POLL_TIMEOUT.
+func (v *Si7021) SetPolling(interval, timeout time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.pollInterval = interval
+	v.pollTimeout = timeout
+}
+
+// GetPolling return poll interval and timeout in effect.
+func (v *Si7021) GetPolling() (time.Duration, time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.polling()
+}
+
+// polling return poll interval and timeout in effect, so
+// neither zero value Si7021 nor non-positive values set
+// make driver spin on bus or time out at once.
+func (v *Si7021) polling() (time.Duration, time.Duration) {
+	interval, timeout := v.pollInterval, v.pollTimeout
+	if interval <= 0 {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+	if timeout <= 0 {
+		timeout = DEFAULT_POLL_TIMEOUT
+	}
+	return interval, timeout
+}
+
 // ReadFirmwareVersion return sensor firmware revision.
-func (v *Si7021) ReadFirmwareVersion(i2c *i2c.I2C) (FirmwareVersion, error) {
-	_, err := i2c.WriteBytes(CMD_READ_FIRMWARE_REV)
+func (v *Si7021) ReadFirmwareVersion(bus Bus) (FirmwareVersion, error) {
+	return v.ReadFirmwareVersionCtx(context.Background(), bus)
+}
+
+// ReadFirmwareVersionCtx is the same as ReadFirmwareVersion, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadFirmwareVersionCtx(ctx context.Context, bus Bus) (FirmwareVersion, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.readFirmwareVersion(ctx, bus)
+}
+
+func (v *Si7021) readFirmwareVersion(ctx context.Context, bus Bus) (FirmwareVersion, error) {
+	err := v.checkSupported("firmware revision", CMD_READ_FIRMWARE_REV)
 	if err != nil {
 		return 0, err
 	}
 	buf2 := make([]byte, 1)
-	_, err = i2c.ReadBytes(buf2)
+	err = v.withRetry(ctx, "firmware revision read", func() error {
+		return writeReadBytes(ctx, bus, CMD_READ_FIRMWARE_REV, buf2)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -210,27 +337,37 @@ type SerialNumberRaw struct {
 }
 
 // ReadSerialNumberRaw read sensor serial number to the struct.
-func (v *Si7021) ReadSerialNumberRaw(i2c *i2c.I2C) (*SerialNumberRaw, error) {
+func (v *Si7021) ReadSerialNumberRaw(bus Bus) (*SerialNumberRaw, error) {
+	return v.ReadSerialNumberRawCtx(context.Background(), bus)
+}
+
+// ReadSerialNumberRawCtx is the same as ReadSerialNumberRaw, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadSerialNumberRawCtx(ctx context.Context, bus Bus) (*SerialNumberRaw, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	var sn *SerialNumberRaw
+	err := v.withRetry(ctx, "serial number read", func() error {
+		var err error
+		sn, err = v.readSerialNumberRaw(ctx, bus)
+		return err
+	})
+	return sn, err
+}
+
+func (v *Si7021) readSerialNumberRaw(ctx context.Context, bus Bus) (*SerialNumberRaw, error) {
 	lg.Debug("Reading sensor serial number...")
 	const bytesCount1stRead = 8
 	const bytesCount2ndRead = 6
 	buf2 := make([]byte, bytesCount1stRead+bytesCount2ndRead)
-	_, err := i2c.WriteBytes(CMD_READ_ID_1ST_PART)
-	if err != nil {
-		return nil, err
-	}
 	buf1 := make([]byte, bytesCount1stRead)
-	_, err = i2c.ReadBytes(buf1)
+	err := writeReadBytes(ctx, bus, CMD_READ_ID_1ST_PART, buf1)
 	if err != nil {
 		return nil, err
 	}
 	buf2 = append([]byte{}, buf1[0:]...)
-	_, err = i2c.WriteBytes(CMD_READ_ID_2ND_PART)
-	if err != nil {
-		return nil, err
-	}
 	buf1 = make([]byte, bytesCount2ndRead)
-	_, err = i2c.ReadBytes(buf1)
+	err = writeReadBytes(ctx, bus, CMD_READ_ID_2ND_PART, buf1)
 	if err != nil {
 		return nil, err
 	}
@@ -248,10 +385,57 @@ func (v *Si7021) ReadSerialNumberRaw(i2c *i2c.I2C) (*SerialNumberRaw, error) {
 }
 
 // ReadSerialNumberRaw read sensor serial number to the struct.
-func (v *Si7021) ReadSerialNumber(i2c *i2c.I2C) (int64, error) {
-	sn, err := v.ReadSerialNumberRaw(i2c)
+func (v *Si7021) ReadSerialNumber(bus Bus) (int64, error) {
+	return v.ReadSerialNumberCtx(context.Background(), bus)
+}
+
+// ReadSerialNumberCtx is the same as ReadSerialNumber, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadSerialNumberCtx(ctx context.Context, bus Bus) (int64, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	_, sn2, err := v.readSerialNumber(ctx, bus)
+	return sn2, err
+}
+
+// readSerialNumber read electronic ID, verify its CRCs
+// and return both raw ID and serial number.
+func (v *Si7021) readSerialNumber(ctx context.Context, bus Bus) (*SerialNumberRaw, int64, error) {
+	var sn *SerialNumberRaw
+	// Retry raw read together with CRC verification.
+	err := v.withRetry(ctx, "serial number read", func() error {
+		var err error
+		sn, err = v.readSerialNumberRaw(ctx, bus)
+		if err != nil {
+			return err
+		}
+		return verifySerialNumberCRC(sn, v.chip().IDLayout)
+	})
 	if err != nil {
-		return 0, err
+		return nil, 0, err
+	}
+	return sn, serialNumberFromRaw(sn, v.chip().IDLayout), nil
+}
+
+// serialNumberFromRaw build serial number from electronic ID.
+// Fields of SerialNumberRaw are named after Silicon Labs
+// format, Sensirion format bytes keep the same positions.
+func serialNumberFromRaw(sn *SerialNumberRaw, layout IDLayout) int64 {
+	if layout == ID_LAYOUT_SENSIRION {
+		// SNA1 SNA0 SNB3 SNB2 SNB1 SNB0 SNC1 SNC0
+		return int64(sn.SNB1)<<56 + int64(sn.SNB0)<<48 + int64(sn.SNA3)<<40 +
+			int64(sn.SNA2)<<32 + int64(sn.SNA1)<<24 + int64(sn.SNA0)<<16 +
+			int64(sn.SNB3)<<8 + int64(sn.SNB2)
+	}
+	return int64(sn.SNA3)<<56 + int64(sn.SNA2)<<48 + int64(sn.SNA1)<<40 +
+		int64(sn.SNA0)<<32 + int64(sn.SNB3)<<24 + int64(sn.SNB2)<<16 +
+		int64(sn.SNB1)<<8 + int64(sn.SNB0)
+}
+
+// verifySerialNumberCRC check all CRCs of electronic ID.
+func verifySerialNumberCRC(sn *SerialNumberRaw, layout IDLayout) error {
+	if layout == ID_LAYOUT_SENSIRION {
+		return verifySensirionIDCRC(sn)
 	}
 	crcSna3 := calcCRC_SI7021(0x0, []byte{sn.SNA3})
 	crcSna2 := calcCRC_SI7021(crcSna3, []byte{sn.SNA2})
@@ -259,29 +443,71 @@ func (v *Si7021) ReadSerialNumber(i2c *i2c.I2C) (int64, error) {
 	crcSna0 := calcCRC_SI7021(crcSna1, []byte{sn.SNA0})
 	crcSnb2 := calcCRC_SI7021(0x0, []byte{sn.SNB3, sn.SNB2})
 	crcSnb0 := calcCRC_SI7021(crcSnb2, []byte{sn.SNB1, sn.SNB0})
-	if crcSna3 != sn.CRC_SNA3 ||
-		crcSna2 != sn.CRC_SNA2 ||
-		crcSna1 != sn.CRC_SNA1 ||
-		crcSna0 != sn.CRC_SNA0 ||
-		crcSnb2 != sn.CRC_SNB2 ||
-		crcSnb0 != sn.CRC_SNB0 {
-		err := errors.New(spew.Sprintf(
-			"Some CRCs equalities are not valid: %v = %v, %v = %v,"+
-				"%v = %v, %v = %v, %v = %v, %v = %v",
-			sn.CRC_SNA3, crcSna3, sn.CRC_SNA2, crcSna2,
-			sn.CRC_SNA1, crcSna1, sn.CRC_SNA0, crcSna0,
-			sn.CRC_SNB2, crcSnb2, sn.CRC_SNB0, crcSnb0))
-		return 0, err
+	crcs := []struct {
+		field            string
+		actual, expected byte
+	}{
+		{"SNA3", sn.CRC_SNA3, crcSna3},
+		{"SNA2", sn.CRC_SNA2, crcSna2},
+		{"SNA1", sn.CRC_SNA1, crcSna1},
+		{"SNA0", sn.CRC_SNA0, crcSna0},
+		{"SNB3-SNB2", sn.CRC_SNB2, crcSnb2},
+		{"SNB1-SNB0", sn.CRC_SNB0, crcSnb0},
 	}
-	sn2 := int64(sn.SNA3)<<56 + int64(sn.SNA2)<<48 + int64(sn.SNA1)<<40 +
-		int64(sn.SNA0)<<32 + int64(sn.SNB3)<<24 + int64(sn.SNB2)<<16 +
-		int64(sn.SNB1)<<8 + int64(sn.SNB0)
-	return sn2, nil
+	for _, item := range crcs {
+		if item.actual != item.expected {
+			err := &CRCError{Field: item.field,
+				Expected: item.expected, Actual: item.actual}
+			return err
+		}
+	}
+	return nil
+}
+
+// verifySensirionIDCRC check CRCs of electronic ID in Sensirion
+// format, where each CRC cover preceding byte or pair of bytes only.
+func verifySensirionIDCRC(sn *SerialNumberRaw) error {
+	crcs := []struct {
+		field            string
+		actual, expected byte
+	}{
+		{"SNB3", sn.CRC_SNA3, calcCRC_SI7021(0x0, []byte{sn.SNA3})},
+		{"SNB2", sn.CRC_SNA2, calcCRC_SI7021(0x0, []byte{sn.SNA2})},
+		{"SNB1", sn.CRC_SNA1, calcCRC_SI7021(0x0, []byte{sn.SNA1})},
+		{"SNB0", sn.CRC_SNA0, calcCRC_SI7021(0x0, []byte{sn.SNA0})},
+		{"SNC1-SNC0", sn.CRC_SNB2, calcCRC_SI7021(0x0, []byte{sn.SNB3, sn.SNB2})},
+		{"SNA1-SNA0", sn.CRC_SNB0, calcCRC_SI7021(0x0, []byte{sn.SNB1, sn.SNB0})},
+	}
+	for _, item := range crcs {
+		if item.actual != item.expected {
+			err := &CRCError{Field: item.field,
+				Expected: item.expected, Actual: item.actual}
+			return err
+		}
+	}
+	return nil
 }
 
 // ReadSensorType return sensor model.
-func (v *Si7021) ReadSensoreType(i2c *i2c.I2C) (SensorType, error) {
-	sn, err := v.ReadSerialNumberRaw(i2c)
+func (v *Si7021) ReadSensoreType(bus Bus) (SensorType, error) {
+	return v.ReadSensoreTypeCtx(context.Background(), bus)
+}
+
+// ReadSensoreTypeCtx is the same as ReadSensoreType, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadSensoreTypeCtx(ctx context.Context, bus Bus) (SensorType, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if !v.chip().SensorTypeInID {
+		return 0, &UnsupportedError{Feature: "sensor type",
+			Reason: spew.Sprintf("%v do not report sensor type in ID", v.chip().Model)}
+	}
+	var sn *SerialNumberRaw
+	err := v.withRetry(ctx, "serial number read", func() error {
+		var err error
+		sn, err = v.readSerialNumberRaw(ctx, bus)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -289,40 +515,75 @@ func (v *Si7021) ReadSensoreType(i2c *i2c.I2C) (SensorType, error) {
 	return st, nil
 }
 
-func (v *Si7021) readUserReg(i2c *i2c.I2C) (byte, error) {
-	if v.lastUserReg == nil {
-		_, err := i2c.WriteBytes(CMD_READ_USER_REG_1)
-		if err != nil {
-			return 0, err
-		}
-		buf1 := make([]byte, 1)
-		_, err = i2c.ReadBytes(buf1)
-		if err != nil {
-			return 0, err
-		}
-		v.lastUserReg = &buf1[0]
+// readUserReg read user register 1 and
+// remember resolution sensor configured to.
+func (v *Si7021) readUserReg(ctx context.Context, bus Bus) (byte, error) {
+	buf1 := make([]byte, 1)
+	err := v.withRetry(ctx, "user register read", func() error {
+		return writeReadBytes(ctx, bus, CMD_READ_USER_REG_1, buf1)
+	})
+	if err != nil {
+		return 0, err
 	}
-	return *v.lastUserReg, nil
+	v.resolution = UserRegFlag(buf1[0]) & RES_RH_TEMP_MASK
+	return buf1[0], nil
 }
 
 // SetMeasureResolution set up sensor
 // temprature and humidity measure accuracy.
-func (v *Si7021) SetMeasureResolution(i2c *i2c.I2C, res UserRegFlag) error {
+func (v *Si7021) SetMeasureResolution(bus Bus, res UserRegFlag) error {
+	return v.SetMeasureResolutionCtx(context.Background(), bus, res)
+}
+
+// SetMeasureResolutionCtx is the same as SetMeasureResolution, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) SetMeasureResolutionCtx(ctx context.Context, bus Bus, res UserRegFlag) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Setting measure resolution...")
-	ur, err := v.readUserReg(i2c)
-	if err != nil {
-		return err
+	return v.updateUserRegister(ctx, bus, func(ur *UserRegister) {
+		ur.Resolution = res & RES_RH_TEMP_MASK
+	})
+}
+
+// GetConversionTime return datasheet maximum conversion
+// time of relative humidity and temperature for resolution.
+// Keep in mind, that humidity measurement is followed
+// by temperature measurement, so it take sum of both.
+func GetConversionTime(res UserRegFlag) (time.Duration, time.Duration) {
+	const us = time.Microsecond
+	switch res & RES_RH_TEMP_MASK {
+	case RES_RH_8BIT_TEMP_12BIT:
+		return 3100 * us, 3800 * us
+	case RES_RH_10BIT_TEMP_13BIT:
+		return 4500 * us, 6200 * us
+	case RES_RH_11BIT_TEMP_11BIT:
+		return 7000 * us, 2400 * us
+	default:
+		return 12000 * us, 10800 * us
 	}
-	ur = ur&(^byte(RES_RH_TEMP_MASK)) | (byte)(res)
-	v.lastUserReg = &ur
-	_, err = i2c.WriteBytes(append(CMD_WRITE_USER_REG_1, ur))
-	return err
+}
+
+// GetExpectedConversionTime return maximum conversion time of
+// relative humidity and temperature for resolution sensor
+// configured to (last read or set up by driver).
+func (v *Si7021) GetExpectedConversionTime() (time.Duration, time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.chip().GetConversionTime(v.resolution)
 }
 
 // GetMeasureResolution read current sensor measure accuracy.
-func (v *Si7021) GetMeasureResolution(i2c *i2c.I2C) (UserRegFlag, error) {
-	v.lastUserReg = nil
-	ur, err := v.readUserReg(i2c)
+func (v *Si7021) GetMeasureResolution(bus Bus) (UserRegFlag, error) {
+	return v.GetMeasureResolutionCtx(context.Background(), bus)
+}
+
+// GetMeasureResolutionCtx is the same as GetMeasureResolution, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) GetMeasureResolutionCtx(ctx context.Context, bus Bus) (UserRegFlag, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	ur, err := v.readUserReg(ctx, bus)
 	if err != nil {
 		return 0, err
 	}
@@ -330,26 +591,40 @@ func (v *Si7021) GetMeasureResolution(i2c *i2c.I2C) (UserRegFlag, error) {
 }
 
 // SetHeaterStatus enable of disable internal heater.
-func (v *Si7021) SetHeaterStatus(i2c *i2c.I2C, enableHeater bool) error {
+func (v *Si7021) SetHeaterStatus(bus Bus, enableHeater bool) error {
+	return v.SetHeaterStatusCtx(context.Background(), bus, enableHeater)
+}
+
+// SetHeaterStatusCtx is the same as SetHeaterStatus, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) SetHeaterStatusCtx(ctx context.Context, bus Bus, enableHeater bool) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Setting heater on/off...")
-	ur, err := v.readUserReg(i2c)
-	if err != nil {
-		return err
-	}
-	ur = ur & (^byte(HEATER_ENABLED))
+	// Switching heater off is always allowed.
 	if enableHeater {
-		ur = ur | byte(HEATER_ENABLED)
+		err := v.checkCapability("heater", CAP_HEATER)
+		if err != nil {
+			return err
+		}
 	}
-	v.lastUserReg = &ur
-	_, err = i2c.WriteBytes(append(CMD_WRITE_USER_REG_1, ur))
-	return err
+	return v.updateUserRegister(ctx, bus, func(ur *UserRegister) {
+		ur.HeaterEnabled = enableHeater
+	})
 }
 
 // GetHeaterStatus return heater status: on (true) or off (false).
-func (v *Si7021) GetHeaterStatus(i2c *i2c.I2C) (bool, error) {
+func (v *Si7021) GetHeaterStatus(bus Bus) (bool, error) {
+	return v.GetHeaterStatusCtx(context.Background(), bus)
+}
+
+// GetHeaterStatusCtx is the same as GetHeaterStatus, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) GetHeaterStatusCtx(ctx context.Context, bus Bus) (bool, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Getting heater status...")
-	v.lastUserReg = nil
-	ur, err := v.readUserReg(i2c)
+	ur, err := v.readUserReg(ctx, bus)
 	if err != nil {
 		return false, err
 	}
@@ -357,10 +632,17 @@ func (v *Si7021) GetHeaterStatus(i2c *i2c.I2C) (bool, error) {
 }
 
 // GetVoltageStatus provide power supply voltage low: low (true) or OK (false).
-func (v *Si7021) GetVoltageLow(i2c *i2c.I2C) (bool, error) {
+func (v *Si7021) GetVoltageLow(bus Bus) (bool, error) {
+	return v.GetVoltageLowCtx(context.Background(), bus)
+}
+
+// GetVoltageLowCtx is the same as GetVoltageLow, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) GetVoltageLowCtx(ctx context.Context, bus Bus) (bool, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Getting voltage low status...")
-	v.lastUserReg = nil
-	ur, err := v.readUserReg(i2c)
+	ur, err := v.readUserReg(ctx, bus)
 	if err != nil {
 		return false, err
 	}
@@ -371,23 +653,50 @@ func (v *Si7021) GetVoltageLow(i2c *i2c.I2C) (bool, error) {
 // heating gradation. Remeber, when heater is on
 // temprature provided by sensor is not correspond
 // to real ambient temprature.
-func (v *Si7021) SetHeaterLevel(i2c *i2c.I2C, level HeaterLevel) error {
+func (v *Si7021) SetHeaterLevel(bus Bus, level HeaterLevel) error {
+	return v.SetHeaterLevelCtx(context.Background(), bus, level)
+}
+
+// SetHeaterLevelCtx is the same as SetHeaterLevel, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) SetHeaterLevelCtx(ctx context.Context, bus Bus, level HeaterLevel) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Setting heater level...")
 	var hcr byte
 	hcr = (byte)(level)
-	_, err := i2c.WriteBytes(append(CMD_WRITE_HEATER_REG, hcr))
+	cmd := append(CMD_WRITE_HEATER_REG, hcr)
+	err := v.checkSupported("heater level", cmd)
+	if err != nil {
+		return err
+	}
+	err = writeBytes(ctx, bus, cmd)
 	return err
 }
 
 // GetHeaterLevel return sensor heating gradation.
-func (v *Si7021) GetHeaterLevel(i2c *i2c.I2C) (HeaterLevel, error) {
+func (v *Si7021) GetHeaterLevel(bus Bus) (HeaterLevel, error) {
+	return v.GetHeaterLevelCtx(context.Background(), bus)
+}
+
+// GetHeaterLevelCtx is the same as GetHeaterLevel, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) GetHeaterLevelCtx(ctx context.Context, bus Bus) (HeaterLevel, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Getting heater level...")
-	_, err := i2c.WriteBytes(CMD_READ_HEATER_REG)
+	return v.readHeaterLevel(ctx, bus)
+}
+
+func (v *Si7021) readHeaterLevel(ctx context.Context, bus Bus) (HeaterLevel, error) {
+	err := v.checkSupported("heater level", CMD_READ_HEATER_REG)
 	if err != nil {
 		return 0, err
 	}
 	buf1 := make([]byte, 1)
-	_, err = i2c.ReadBytes(buf1)
+	err = v.withRetry(ctx, "heater register read", func() error {
+		return writeReadBytes(ctx, bus, CMD_READ_HEATER_REG, buf1)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -395,94 +704,263 @@ func (v *Si7021) GetHeaterLevel(i2c *i2c.I2C) (HeaterLevel, error) {
 }
 
 // Reset reboot a sensor.
-func (v *Si7021) Reset(i2c *i2c.I2C) error {
+func (v *Si7021) Reset(bus Bus) error {
+	return v.ResetCtx(context.Background(), bus)
+}
+
+// ResetCtx is the same as Reset, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ResetCtx(ctx context.Context, bus Bus) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Reset sensor...")
-	_, err := i2c.WriteBytes(CMD_RESET)
+	err := writeBytes(ctx, bus, CMD_RESET)
 	if err != nil {
 		return err
 	}
+	// Sensor restore default register values, once
+	// command is sent, even if powerup wait is cancelled.
+	v.resolution = RES_RH_12BIT_TEMP_14BIT
+	// Identity is read again after reset.
+	v.deviceInfo = nil
 	// Powerup time
-	time.Sleep(time.Millisecond * 15)
+	err = sleepContext(ctx, time.Millisecond*15)
 	return err
 }
 
-func (v *Si7021) doMeasure(i2c *i2c.I2C, cmd []byte, withCRC bool) (uint16, byte, error) {
-	_, err := i2c.WriteBytes(cmd)
-	if err != nil {
-		return 0, 0, err
+// measureKind denote type of measurement.
+type measureKind int
+
+const (
+	measureHumidity measureKind = iota
+	measureTemperature
+	measureTempFromPrevious
+)
+
+// measureCmd return command for measurement kind
+// according to measure mode selected.
+func (v *Si7021) measureCmd(kind measureKind) []byte {
+	hold := v.measureMode == MEASURE_HOLD_MASTER
+	switch kind {
+	case measureHumidity:
+		if hold {
+			return CMD_REL_HUM_CSE
+		}
+		return CMD_REL_HUM
+	case measureTemperature:
+		if hold {
+			return CMD_TEMPRATURE_CSE
+		}
+		return CMD_TEMPRATURE
+	default:
+		return CMD_TEMP_FROM_PREVIOUS
 	}
-	// Wait according to conversion time specification
-	time.Sleep(time.Millisecond * (12 + 11))
+}
 
+// conversionWait return maximum time which measurement
+// take at active resolution. On chips with temperature
+// from previous RH measurement, humidity measurement
+// include temperature measurement as well.
+func (v *Si7021) conversionWait(kind measureKind) time.Duration {
+	rhTime, tempTime := v.chip().GetConversionTime(v.resolution)
+	switch kind {
+	case measureHumidity:
+		if !v.capabilities().Has(CAP_TEMP_FROM_PREVIOUS) {
+			return rhTime
+		}
+		return rhTime + tempTime
+	case measureTemperature:
+		return tempTime
+	default:
+		return 0
+	}
+}
+
+// pollResult repeat reading measurement result, while
+// sensor NACK read requests due to conversion in progress.
+func (v *Si7021) pollResult(ctx context.Context, bus Bus, buf []byte) error {
+	interval, timeout := v.polling()
+	start := time.Now()
+	for {
+		err := readBytes(ctx, bus, buf)
+		if err == nil {
+			return nil
+		}
+		if time.Since(start) >= timeout {
+			return &TimeoutError{Timeout: timeout, LastErr: err}
+		}
+		err = sleepContext(ctx, interval)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// clearStatusBits return measurement code
+// without status bits, chip may put there.
+func (v *Si7021) clearStatusBits(buf []byte) uint16 {
+	return getU16BE(buf) &^ v.chip().StatusBitsMask
+}
+
+func (v *Si7021) doMeasure(ctx context.Context, bus Bus, kind measureKind) (uint16, byte, error) {
 	const dataBytesCount = 2
 	const crcBytesCount = 1
+	if kind == measureTempFromPrevious && !v.capabilities().Has(CAP_TEMP_FROM_PREVIOUS) {
+		// Chip can't return temperature of RH
+		// measurement, so measure it separately.
+		kind = measureTemperature
+	}
+	// Temperature from previous RH measurement is
+	// ready immediately and provided without CRC.
+	withCRC := kind != measureTempFromPrevious
+	cmd := v.measureCmd(kind)
+	buf := make([]byte, dataBytesCount)
 	if withCRC {
-		var data struct {
-			Data [2]byte
-			CRC  byte
+		buf = make([]byte, dataBytesCount+crcBytesCount)
+	}
+	if v.measureMode == MEASURE_HOLD_MASTER || kind == measureTempFromPrevious {
+		// In Hold Master Mode sensor stretch clock
+		// until conversion complete, so read immediately.
+		err := writeReadBytes(ctx, bus, cmd, buf)
+		if err != nil {
+			return 0, 0, err
 		}
-		err := readDataToStruct(i2c, dataBytesCount+crcBytesCount, binary.BigEndian, &data)
+	} else if v.measureMode == MEASURE_NO_HOLD_POLLING {
+		err := writeBytes(ctx, bus, cmd)
 		if err != nil {
 			return 0, 0, err
 		}
-		calcCRC := calcCRC_SI7021(0x0, data.Data[:dataBytesCount])
-		if data.CRC != calcCRC {
-			err := errors.New(spew.Sprintf(
-				"CRCs doesn't match: CRC from sensor (0x%0X) != calculated CRC (0x%0X)",
-				data.CRC, calcCRC))
+		err = v.pollResult(ctx, bus, buf)
+		if err != nil {
 			return 0, 0, err
-		} else {
-			lg.Debugf("CRCs verified: CRC from sensor (0x%0X) = calculated CRC (0x%0X)",
-				data.CRC, calcCRC)
 		}
-		return getU16BE(data.Data[:dataBytesCount]), data.CRC, nil
 	} else {
-		var data struct {
-			Data [2]byte
+		err := writeBytes(ctx, bus, cmd)
+		if err != nil {
+			return 0, 0, err
+		}
+		// Wait according to conversion time specification
+		err = sleepContext(ctx, v.conversionWait(kind))
+		if err != nil {
+			return 0, 0, err
 		}
-		err := readDataToStruct(i2c, dataBytesCount, binary.BigEndian, &data)
+		err = readBytes(ctx, bus, buf)
 		if err != nil {
 			return 0, 0, err
 		}
-		return getU16BE(data.Data[:dataBytesCount]), 0, nil
 	}
+
+	if withCRC {
+		crc := buf[dataBytesCount]
+		calcCRC := calcCRC_SI7021(0x0, buf[:dataBytesCount])
+		if crc != calcCRC {
+			field := "humidity"
+			if kind == measureTemperature {
+				field = "temperature"
+			}
+			// Keep data along with error for those,
+			// who need raw values regardless of CRC.
+			err := &CRCError{Field: field, Expected: calcCRC, Actual: crc}
+			return v.clearStatusBits(buf[:dataBytesCount]), crc, err
+		} else {
+			lg.Debugf("CRCs verified: CRC from sensor (0x%0X) = calculated CRC (0x%0X)",
+				crc, calcCRC)
+		}
+		return v.clearStatusBits(buf[:dataBytesCount]), crc, nil
+	}
+	return v.clearStatusBits(buf[:dataBytesCount]), 0, nil
 }
 
 // ReadUncompHumidity returns uncompensated humidity and CRC.
-func (v *Si7021) ReadUncompHumidity(i2c *i2c.I2C) (uint16, byte, error) {
+func (v *Si7021) ReadUncompHumidity(bus Bus) (uint16, byte, error) {
+	return v.ReadUncompHumidityCtx(context.Background(), bus)
+}
+
+// ReadUncompHumidityCtx is the same as ReadUncompHumidity, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadUncompHumidityCtx(ctx context.Context, bus Bus) (uint16, byte, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Reading uncompensated humidity...")
-	rh, crc, err := v.doMeasure(i2c, CMD_REL_HUM, true)
+	var rh uint16
+	var crc byte
+	err := v.withRetry(ctx, "humidity measurement", func() error {
+		var err error
+		rh, crc, err = v.doMeasure(ctx, bus, measureHumidity)
+		return err
+	})
 	return rh, crc, err
 }
 
 // ReadUncompTemperature returns uncompensated temperature and CRC.
-func (v *Si7021) ReadUncompTemprature(i2c *i2c.I2C) (uint16, byte, error) {
+func (v *Si7021) ReadUncompTemprature(bus Bus) (uint16, byte, error) {
+	return v.ReadUncompTempratureCtx(context.Background(), bus)
+}
+
+// ReadUncompTempratureCtx is the same as ReadUncompTemprature, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadUncompTempratureCtx(ctx context.Context, bus Bus) (uint16, byte, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Reading uncompensated temprature...")
-	temp, crc, err := v.doMeasure(i2c, CMD_TEMPRATURE, true)
+	var temp uint16
+	var crc byte
+	err := v.withRetry(ctx, "temperature measurement", func() error {
+		var err error
+		temp, crc, err = v.doMeasure(ctx, bus, measureTemperature)
+		return err
+	})
 	return temp, crc, err
 }
 
+// UncompHumidityToCentiPercent convert 16-bit humidity code
+// to relative humidity in hundredths of percent, using
+// integer math only: RH = 125 * code / 65536 - 6.
+func UncompHumidityToCentiPercent(uh uint16) int32 {
+	// Add half of divisor to round to nearest.
+	return int32((int64(uh)*12500+32768)>>16) - 600
+}
+
+// UncompTemperatureToCentiCelsius convert 16-bit temperature code
+// to temperature in hundredths of degree celsius, using
+// integer math only: T = 175.72 * code / 65536 - 46.85.
+func UncompTemperatureToCentiCelsius(ut uint16) int32 {
+	// Add half of divisor to round to nearest.
+	return int32((int64(ut)*17572+32768)>>16) - 4685
+}
+
 func (v *Si7021) uncompHumidityToRelativeHumidity(uh uint16) float32 {
-	rh := float32(uh)*125/65536 - 6
-	rh2 := round32(rh, 2)
-	return rh2
+	return float32(UncompHumidityToCentiPercent(uh)) / 100
 }
 
 func (v *Si7021) uncompTemperatureToCelsius(ut uint16) float32 {
-	temp := float32(ut)*175.72/65536 - 46.85
-	temp2 := round32(temp, 2)
-	return temp2
+	return float32(UncompTemperatureToCentiCelsius(ut)) / 100
 }
 
 // ReadUncompHumidityAndTemperature returns
 // uncompensated humidity, temperature and CRC.
-func (v *Si7021) ReadUncompHumidityAndTemprature(i2c *i2c.I2C) (uint16, uint16, error) {
+func (v *Si7021) ReadUncompHumidityAndTemprature(bus Bus) (uint16, uint16, error) {
+	return v.ReadUncompHumidityAndTempratureCtx(context.Background(), bus)
+}
+
+// ReadUncompHumidityAndTempratureCtx is the same as ReadUncompHumidityAndTemprature, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadUncompHumidityAndTempratureCtx(ctx context.Context, bus Bus) (uint16, uint16, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	lg.Debug("Reading uncompensated humidity and temperature...")
-	rh, _, err := v.doMeasure(i2c, CMD_REL_HUM, true)
-	if err != nil {
-		return 0, 0, err
-	}
-	temp, _, err := v.doMeasure(i2c, CMD_TEMP_FROM_PREVIOUS, false)
+	var rh, temp uint16
+	// Retry whole sequence, since temperature
+	// is taken from humidity measurement.
+	err := v.withRetry(ctx, "humidity and temperature measurement", func() error {
+		var err error
+		rh, _, err = v.doMeasure(ctx, bus, measureHumidity)
+		if err != nil {
+			return err
+		}
+		temp, _, err = v.doMeasure(ctx, bus, measureTempFromPrevious)
+		return err
+	})
 	if err != nil {
 		return 0, 0, err
 	}
@@ -490,8 +968,14 @@ func (v *Si7021) ReadUncompHumidityAndTemprature(i2c *i2c.I2C) (uint16, uint16,
 }
 
 // ReadRelativeHumidity return relative humidity.
-func (v *Si7021) ReadRelativeHumidity(i2c *i2c.I2C) (float32, error) {
-	urh, _, err := v.ReadUncompHumidity(i2c)
+func (v *Si7021) ReadRelativeHumidity(bus Bus) (float32, error) {
+	return v.ReadRelativeHumidityCtx(context.Background(), bus)
+}
+
+// ReadRelativeHumidityCtx is the same as ReadRelativeHumidity, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadRelativeHumidityCtx(ctx context.Context, bus Bus) (float32, error) {
+	urh, _, err := v.ReadUncompHumidityCtx(ctx, bus)
 	if err != nil {
 		return 0, err
 	}
@@ -500,8 +984,14 @@ func (v *Si7021) ReadRelativeHumidity(i2c *i2c.I2C) (float32, error) {
 }
 
 // ReadTemperature return temprature.
-func (v *Si7021) ReadTemperature(i2c *i2c.I2C) (float32, error) {
-	ut, _, err := v.ReadUncompTemprature(i2c)
+func (v *Si7021) ReadTemperature(bus Bus) (float32, error) {
+	return v.ReadTemperatureCtx(context.Background(), bus)
+}
+
+// ReadTemperatureCtx is the same as ReadTemperature, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadTemperatureCtx(ctx context.Context, bus Bus) (float32, error) {
+	ut, _, err := v.ReadUncompTempratureCtx(ctx, bus)
 	if err != nil {
 		return 0, err
 	}
@@ -511,8 +1001,14 @@ func (v *Si7021) ReadTemperature(i2c *i2c.I2C) (float32, error) {
 
 // ReadRelativeHumidityAndTemperature return
 // relative humidity and temperature.
-func (v *Si7021) ReadRelativeHumidityAndTemperature(i2c *i2c.I2C) (float32, float32, error) {
-	urh, ut, err := v.ReadUncompHumidityAndTemprature(i2c)
+func (v *Si7021) ReadRelativeHumidityAndTemperature(bus Bus) (float32, float32, error) {
+	return v.ReadRelativeHumidityAndTemperatureCtx(context.Background(), bus)
+}
+
+// ReadRelativeHumidityAndTemperatureCtx is the same as ReadRelativeHumidityAndTemperature, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadRelativeHumidityAndTemperatureCtx(ctx context.Context, bus Bus) (float32, float32, error) {
+	urh, ut, err := v.ReadUncompHumidityAndTempratureCtx(ctx, bus)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -520,3 +1016,70 @@ func (v *Si7021) ReadRelativeHumidityAndTemperature(i2c *i2c.I2C) (float32, floa
 	temp := v.uncompTemperatureToCelsius(ut)
 	return rh, temp, nil
 }
+
+// Relative humidity temperature compensation parameters:
+// reference temperature (*C), which RH accuracy is
+// specified for, and RH temperature coefficient (%RH/*C).
+const (
+	RH_COMPENSATION_REF_TEMP = 30
+	RH_COMPENSATION_COEFF    = -0.05
+)
+
+// CompensateRelativeHumidity apply Si70xx temperature coefficient
+// to relative humidity measured at temperature away from
+// reference one, and clamp result to 0-100% range.
+// Use ChipVariant.CompensateRelativeHumidity for other chips.
+func CompensateRelativeHumidity(rh, temp float32) float32 {
+	return compensateRelativeHumidity(rh, temp,
+		RH_COMPENSATION_REF_TEMP, RH_COMPENSATION_COEFF)
+}
+
+func compensateRelativeHumidity(rh, temp, refTemp, coeff float32) float32 {
+	rh2 := rh + (refTemp-temp)*coeff
+	if rh2 < 0 {
+		rh2 = 0
+	} else if rh2 > 100 {
+		rh2 = 100
+	}
+	return round32(rh2, 2)
+}
+
+// ReadCompensatedRelativeHumidity return temperature compensated
+// relative humidity (clamped to 0-100% range) along with
+// raw (not compensated) relative humidity and temperature,
+// taken from the same humidity measurement.
+func (v *Si7021) ReadCompensatedRelativeHumidity(bus Bus) (float32, float32, float32, error) {
+	return v.ReadCompensatedRelativeHumidityCtx(context.Background(), bus)
+}
+
+// ReadCompensatedRelativeHumidityCtx is the same as ReadCompensatedRelativeHumidity, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadCompensatedRelativeHumidityCtx(ctx context.Context, bus Bus) (float32, float32, float32, error) {
+	urh, ut, err := v.ReadUncompHumidityAndTempratureCtx(ctx, bus)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	rh := v.uncompHumidityToRelativeHumidity(urh)
+	temp := v.uncompTemperatureToCelsius(ut)
+	v.mutex.Lock()
+	cv := v.chip()
+	v.mutex.Unlock()
+	return cv.CompensateRelativeHumidity(rh, temp), rh, temp, nil
+}
+
+// ReadRelativeHumidityAndTemperatureCenti return relative humidity
+// in hundredths of percent and temperature in hundredths of degree
+// celsius, calculated from sensor codes with integer math only.
+func (v *Si7021) ReadRelativeHumidityAndTemperatureCenti(bus Bus) (int32, int32, error) {
+	return v.ReadRelativeHumidityAndTemperatureCentiCtx(context.Background(), bus)
+}
+
+// ReadRelativeHumidityAndTemperatureCentiCtx is the same as ReadRelativeHumidityAndTemperatureCenti, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadRelativeHumidityAndTemperatureCentiCtx(ctx context.Context, bus Bus) (int32, int32, error) {
+	urh, ut, err := v.ReadUncompHumidityAndTempratureCtx(ctx, bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return UncompHumidityToCentiPercent(urh), UncompTemperatureToCentiCelsius(ut), nil
+}
@@ -0,0 +1,148 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestResetCancelledDuringPowerup(t *testing.T) {
+	sim := NewSimulator()
+	sensor := NewSi7021()
+	if _, err := sensor.ReadDeviceInfo(sim); err != nil {
+		t.Fatal(err)
+	}
+	if err := sensor.SetMeasureResolution(sim, RES_RH_8BIT_TEMP_12BIT); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Millisecond)
+	defer cancel()
+	err := sensor.ResetCtx(ctx, sim)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if res := UserRegFlag(sim.UserReg()) & RES_RH_TEMP_MASK; res != RES_RH_12BIT_TEMP_14BIT {
+		t.Fatalf("sensor was not reset, resolution %v", res)
+	}
+	rhTime, tempTime := sensor.GetExpectedConversionTime()
+	rhExp, tempExp := GetConversionTime(RES_RH_12BIT_TEMP_14BIT)
+	if rhTime != rhExp || tempTime != tempExp {
+		t.Errorf("expected conversion time %v/%v, got %v/%v", rhExp, tempExp, rhTime, tempTime)
+	}
+	sim.SetSensorType(SI_7020_TYPE)
+	info, err := sensor.ReadDeviceInfo(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SensorType != SI_7020_TYPE {
+		t.Errorf("device info is not read again after reset: %v", info.SensorType)
+	}
+}
+
+// roundRat round exact value x to nearest integer, half up.
+func roundRat(x *big.Rat) int64 {
+	half := new(big.Rat).Add(x, big.NewRat(1, 2))
+	// Euclidean division give floor for positive denominator.
+	return new(big.Int).Div(half.Num(), half.Denom()).Int64()
+}
+
+// exactCenti return round(scale * code / 65536 + offset), calculated with rational numbers.
+func exactCenti(code uint16, scale, offset int64) int32 {
+	x := big.NewRat(scale*int64(code), 65536)
+	x.Add(x, big.NewRat(offset, 1))
+	return int32(roundRat(x))
+}
+
+func TestUncompToCentiTable(t *testing.T) {
+	tests := []struct {
+		code uint16
+		temp int32
+		rh   int32
+	}{
+		{0x0000, -4685, -600},
+		{0x6B50, 2681, 4640},
+		{0x7C80, 3861, 5479},
+		{0x8000, 4101, 5650},
+		{0xFFFF, 12887, 11900},
+	}
+	for _, test := range tests {
+		if got := UncompTemperatureToCentiCelsius(test.code); got != test.temp {
+			t.Errorf("UncompTemperatureToCentiCelsius(0x%04X) = %d, want %d", test.code, got, test.temp)
+		}
+		if got := UncompHumidityToCentiPercent(test.code); got != test.rh {
+			t.Errorf("UncompHumidityToCentiPercent(0x%04X) = %d, want %d", test.code, got, test.rh)
+		}
+	}
+}
+
+func TestUncompToCentiExact(t *testing.T) {
+	for i := 0; i <= 0xFFFF; i++ {
+		code := uint16(i)
+		if got, want := UncompTemperatureToCentiCelsius(code), exactCenti(code, 17572, -4685); got != want {
+			t.Fatalf("UncompTemperatureToCentiCelsius(0x%04X) = %d, want %d", code, got, want)
+		}
+		if got, want := UncompHumidityToCentiPercent(code), exactCenti(code, 12500, -600); got != want {
+			t.Fatalf("UncompHumidityToCentiPercent(0x%04X) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+// countingBus pass operations to simulator and count reads.
+type countingBus struct {
+	sim   *Simulator
+	reads int
+}
+
+func (v *countingBus) WriteBytes(buf []byte) (int, error) {
+	return v.sim.WriteBytes(buf)
+}
+
+func (v *countingBus) ReadBytes(buf []byte) (int, error) {
+	v.reads++
+	return v.sim.ReadBytes(buf)
+}
+
+func TestPollingDefaults(t *testing.T) {
+	sensor := NewSi7021()
+	sensor.SetPolling(0, -time.Second)
+	if interval, timeout := sensor.GetPolling(); interval != DEFAULT_POLL_INTERVAL ||
+		timeout != DEFAULT_POLL_TIMEOUT {
+		t.Errorf("polling %v/%v, expected defaults", interval, timeout)
+	}
+	for _, sensor := range []*Si7021{sensor, {}} {
+		sensor.SetMeasureMode(MEASURE_NO_HOLD_POLLING)
+		sim := NewSimulator()
+		sim.SetConversionTime(20 * time.Millisecond)
+		bus := &countingBus{sim: sim}
+		if _, err := sensor.ReadTemperature(bus); err != nil {
+			t.Fatal(err)
+		}
+		// Conversion take 10 poll intervals.
+		if bus.reads > 15 {
+			t.Errorf("result is polled %d times", bus.reads)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/d2r2/go-si7021/psychrometrics"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Register values which sensor has after power up or reset.
+const (
+	SIM_USER_REG_DEFAULT   byte = 0x3A // RH - 12bit, Temperature - 14bit, reserved bits set
+	SIM_HEATER_REG_DEFAULT byte = 0x00 // HEATER_LEVEL_1
+	SIM_USER_REG2_DEFAULT  byte = 0x00 // Si7013: internal VREF, no thermistor correction
+)
+
+// Simulator is a software Si7021 device, which
+// implement Bus interface and answer all commands
+// from CMD_* table the way real sensor do:
+// measurement results are encoded from ambient
+// temperature and humidity with correct CRC bytes,
+// user and heater registers keep written values,
+// electronic ID and firmware revision are reported
+// from configured serial number, sensor type and firmware.
+// Configured as SI_7013_TYPE, it emulate Si7013 analog input too.
+// Use it to run code without I2C hardware.
+type Simulator struct {
+	mutex        sync.Mutex
+	serialNumber int64
+	firmware     FirmwareVersion
+	temperature  float32
+	humidity     float32
+	voltageLow   bool
+	userReg      byte
+	heaterReg    byte
+	// Temperature code saved by last RH measurement,
+	// which CMD_TEMP_FROM_PREVIOUS return.
+	lastTempCode uint16
+	// Bytes prepared for next read operation.
+	response []byte
+	// Measurement conversion duration and
+	// moment when current conversion complete.
+	conversionTime time.Duration
+	readyAt        time.Time
+	// Conversion started in Hold Master Mode.
+	hold bool
+	// Sensor self-heating emulation.
+	selfHeating  *SelfHeatingModel
+	heaterOnTime time.Time
+	// Si7013 analog input emulation.
+	userReg2       byte
+	thermistCoeffs [256]byte
+	analogVoltage  float32
+	supplyVoltage  float32
+	thermistorTemp float32
+	// Emulated chip, nil for Si70xx defined by sensor type.
+	variant *ChipVariant
+}
+
+// NewSimulator returns new simulated Si7021 sensor
+// with some serial number, firmware version 2.0 and
+// ambient environment of 25*C and 50% of humidity.
+func NewSimulator() *Simulator {
+	v := &Simulator{
+		serialNumber:  0x1B2C3D4E<<32 | int64(SI_7021_TYPE)<<24 | 0xFFFF,
+		firmware:      FIRMWARE_VER_2_0,
+		temperature:   25,
+		humidity:      50,
+		userReg:       SIM_USER_REG_DEFAULT,
+		heaterReg:     SIM_HEATER_REG_DEFAULT,
+		userReg2:      SIM_USER_REG2_DEFAULT,
+		supplyVoltage: HEATER_NOMINAL_VOLTAGE,
+	}
+	return v
+}
+
+// SetSerialNumber define 64-bit serial number reported by
+// electronic ID commands. Keep in mind that byte SNB3
+// (bits 24-31) define sensor type.
+func (v *Simulator) SetSerialNumber(sn int64) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.serialNumber = sn
+}
+
+// SetSensorType define sensor type, reported in SNB3 byte
+// of electronic ID.
+func (v *Simulator) SetSensorType(st SensorType) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.serialNumber = v.serialNumber&^(0xFF<<24) | int64(st)<<24
+}
+
+// SetFirmwareVersion define firmware revision reported by sensor.
+func (v *Simulator) SetFirmwareVersion(fv FirmwareVersion) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.firmware = fv
+}
+
+// SetAmbient define temperature (in celsius) and relative
+// humidity (in percents) of environment "measured" by sensor.
+func (v *Simulator) SetAmbient(temp, rh float32) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.temperature = temp
+	v.humidity = rh
+}
+
+// SetConversionTime define how long measurement take.
+// Until conversion complete, sensor NACK read requests
+// in No Hold Master Mode, or stretch clock (block read)
+// in Hold Master Mode. Default is zero (results ready immediately).
+func (v *Simulator) SetConversionTime(d time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.conversionTime = d
+}
+
+// SetSelfHeating define thermal model used to emulate
+// sensor heating, when internal heater is on.
+// Pass nil to disable self-heating (default).
+func (v *Simulator) SetSelfHeating(model *SelfHeatingModel) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.selfHeating = model
+}
+
+// SetAnalogInput define Si7013 analog input state: voltage
+// on VIN input, supply voltage (used when VDDA is selected
+// as reference) and temperature of thermistor, reported
+// when thermistor correction is enabled.
+// Analog commands are answered only when sensor type is SI_7013_TYPE.
+func (v *Simulator) SetAnalogInput(voltage, supply, thermistorTemp float32) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.analogVoltage = voltage
+	v.supplyVoltage = supply
+	v.thermistorTemp = thermistorTemp
+}
+
+// SetChipModel define chip emulated: unsupported commands
+// are rejected, measurement codes carry chip status bits.
+// For Si70xx chips sensor type in ID is changed accordingly,
+// others report ID in Sensirion format (see ID_LAYOUT_SENSIRION)
+// with SNC1 byte cleared, so it is not taken as Si70xx sensor type.
+func (v *Simulator) SetChipModel(model ChipModel) error {
+	cv, err := GetChipVariant(model)
+	if err != nil {
+		return err
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if cv.IDLayout == ID_LAYOUT_SENSIRION {
+		v.serialNumber &^= 0xFF << 8
+		v.variant = &cv
+		return nil
+	}
+	var st SensorType
+	switch model {
+	case CHIP_SI7006:
+		st = SI_7006_TYPE
+	case CHIP_SI7013:
+		st = SI_7013_TYPE
+	case CHIP_SI7020:
+		st = SI_7020_TYPE
+	case CHIP_SI7021:
+		st = SI_7021_TYPE
+	}
+	v.serialNumber = v.serialNumber&^(0xFF<<24) | int64(st)<<24
+	v.variant = &cv
+	return nil
+}
+
+// UserReg2 return current Si7013 user register 2 content.
+func (v *Simulator) UserReg2() byte {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.userReg2
+}
+
+// SetVoltageLow define VDD status bit of user register.
+func (v *Simulator) SetVoltageLow(low bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.voltageLow = low
+}
+
+// UserReg return current user register 1 content.
+func (v *Simulator) UserReg() byte {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.getUserReg()
+}
+
+// HeaterReg return current heater control register content.
+func (v *Simulator) HeaterReg() byte {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.heaterReg
+}
+
+func (v *Simulator) getUserReg() byte {
+	ur := v.userReg &^ byte(VOLTAGE_LOW)
+	if v.voltageLow {
+		ur |= byte(VOLTAGE_LOW)
+	}
+	return ur
+}
+
+// resolutionMasks return masks which leave in
+// RH and temperature codes only bits significant
+// for resolution selected in user register.
+func (v *Simulator) resolutionMasks() (uint16, uint16) {
+	switch UserRegFlag(v.userReg) & RES_RH_TEMP_MASK {
+	case RES_RH_8BIT_TEMP_12BIT:
+		return 0xFF00, 0xFFF0
+	case RES_RH_10BIT_TEMP_13BIT:
+		return 0xFFC0, 0xFFF8
+	case RES_RH_11BIT_TEMP_11BIT:
+		return 0xFFE0, 0xFFE0
+	default:
+		return 0xFFF0, 0xFFFC
+	}
+}
+
+// sensorClimate return temperature and humidity near
+// sensor, taking into account self-heating.
+func (v *Simulator) sensorClimate() (float64, float64) {
+	temp, rh := float64(v.temperature), float64(v.humidity)
+	if v.selfHeating != nil && UserRegFlag(v.userReg)&HEATER_ENABLED != 0 {
+		level := HeaterLevel(v.heaterReg)
+		temp2 := temp + v.selfHeating.TemperatureRise(level, time.Since(v.heaterOnTime))
+		rh = rh * psychrometrics.SaturationVaporPressure(temp) / psychrometrics.SaturationVaporPressure(temp2)
+		temp = temp2
+	}
+	return temp, rh
+}
+
+// temperatureCode convert ambient temperature to 16-bit sensor code.
+func (v *Simulator) temperatureCode() uint16 {
+	temp, _ := v.sensorClimate()
+	code := (temp + 46.85) * 65536 / 175.72
+	_, mask := v.resolutionMasks()
+	return clampCode(code) & mask
+}
+
+// humidityCode convert ambient humidity to 16-bit sensor code.
+func (v *Simulator) humidityCode() uint16 {
+	_, rh := v.sensorClimate()
+	code := (rh + 6) * 65536 / 125
+	mask, _ := v.resolutionMasks()
+	return clampCode(code) & mask
+}
+
+// analogCode convert Si7013 analog input state to 16-bit code.
+func (v *Simulator) analogCode() uint16 {
+	if v.userReg2&USER_REG2_THERMIST_CORR != 0 {
+		code := (float64(v.thermistorTemp) + 46.85) * 65536 / 175.72
+		return clampCode(code)
+	}
+	vref := float64(SI7013_INTERNAL_VREF)
+	if VoltageReference(v.userReg2)&VREF_MASK == VREF_VDDA {
+		vref = float64(v.supplyVoltage)
+	}
+	code := round64(float64(v.analogVoltage)/vref*32768, 0)
+	if code < -32768 {
+		code = -32768
+	} else if code > 32767 {
+		code = 32767
+	}
+	return uint16(int16(code))
+}
+
+func clampCode(code float64) uint16 {
+	if code < 0 {
+		return 0
+	} else if code > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(round64(code, 0))
+}
+
+// withCRC append CRC byte calculated over data.
+func withCRC(data ...byte) []byte {
+	return append(data, calcCRC_SI7021(0x0, data))
+}
+
+// WriteBytes implement Bus interface and process command sent to sensor.
+func (v *Simulator) WriteBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	err := v.processCommand(buf)
+	if err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// ReadBytes implement Bus interface and return response
+// prepared by last command.
+func (v *Simulator) ReadBytes(buf []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if len(v.response) == 0 {
+		return 0, errors.New("Simulator: no data available to read")
+	}
+	if wait := time.Until(v.readyAt); wait > 0 {
+		if !v.hold {
+			return 0, errors.New("Simulator: read NACK, conversion in progress")
+		}
+		// Clock stretching.
+		time.Sleep(wait)
+	}
+	n := copy(buf, v.response)
+	v.response = nil
+	return n, nil
+}
+
+// WriteReadBytes implement WriteReadBus interface.
+func (v *Simulator) WriteReadBytes(wbuf []byte, rbuf []byte) error {
+	_, err := v.WriteBytes(wbuf)
+	if err != nil {
+		return err
+	}
+	_, err = v.ReadBytes(rbuf)
+	return err
+}
+
+// startConversion mark beginning of measurement.
+func (v *Simulator) startConversion(hold bool) {
+	v.readyAt = time.Now().Add(v.conversionTime)
+	v.hold = hold
+}
+
+func (v *Simulator) processCommand(buf []byte) error {
+	v.response = nil
+	v.readyAt = time.Time{}
+	if v.variant != nil && !v.variant.Capabilities.Has(commandCapability(buf)) {
+		return errors.New(spew.Sprintf("Simulator: unknown command %v", buf))
+	}
+	switch {
+	case bytes.Equal(buf, CMD_REL_HUM), bytes.Equal(buf, CMD_REL_HUM_CSE):
+		rh := v.humidityCode()
+		// Humidity measurement make temperature
+		// measurement as well, to compensate RH.
+		v.lastTempCode = v.temperatureCode()
+		if v.variant != nil && v.variant.StatusBitsMask != 0 {
+			// Status bit 1 denote humidity measurement.
+			rh |= 0x2
+		}
+		v.response = withCRC(byte(rh>>8), byte(rh))
+		v.startConversion(bytes.Equal(buf, CMD_REL_HUM_CSE))
+	case bytes.Equal(buf, CMD_TEMPRATURE), bytes.Equal(buf, CMD_TEMPRATURE_CSE):
+		temp := v.temperatureCode()
+		v.response = withCRC(byte(temp>>8), byte(temp))
+		v.startConversion(bytes.Equal(buf, CMD_TEMPRATURE_CSE))
+	case bytes.Equal(buf, CMD_TEMP_FROM_PREVIOUS):
+		// No CRC byte available for this command.
+		v.response = []byte{byte(v.lastTempCode >> 8), byte(v.lastTempCode)}
+	case bytes.Equal(buf, CMD_RESET):
+		v.userReg = SIM_USER_REG_DEFAULT
+		v.heaterReg = SIM_HEATER_REG_DEFAULT
+		v.userReg2 = SIM_USER_REG2_DEFAULT
+	case bytes.Equal(buf, CMD_READ_USER_REG_1):
+		v.response = []byte{v.getUserReg()}
+	case len(buf) == 2 && buf[0] == CMD_WRITE_USER_REG_1[0]:
+		if UserRegFlag(v.userReg)&HEATER_ENABLED == 0 &&
+			UserRegFlag(buf[1])&HEATER_ENABLED != 0 {
+			v.heaterOnTime = time.Now()
+		}
+		// VDD status bit is read only.
+		v.userReg = buf[1]&^byte(VOLTAGE_LOW) | v.userReg&byte(VOLTAGE_LOW)
+	case bytes.Equal(buf, CMD_READ_HEATER_REG):
+		v.response = []byte{v.heaterReg}
+	case len(buf) == 2 && buf[0] == CMD_WRITE_HEATER_REG[0]:
+		// Only lower 4 bits are significant.
+		v.heaterReg = buf[1] & byte(HEATER_LEVEL_MASK)
+	case bytes.Equal(buf, CMD_READ_ID_1ST_PART) && v.sensirionID():
+		// SNB3..SNB0, each with own CRC.
+		for _, b := range []byte{byte(v.serialNumber >> 40), byte(v.serialNumber >> 32),
+			byte(v.serialNumber >> 24), byte(v.serialNumber >> 16)} {
+			v.response = append(v.response, b, calcCRC_SI7021(0x0, []byte{b}))
+		}
+	case bytes.Equal(buf, CMD_READ_ID_2ND_PART) && v.sensirionID():
+		// SNC1, SNC0, CRC, SNA1, SNA0, CRC.
+		v.response = append(withCRC(byte(v.serialNumber>>8), byte(v.serialNumber)),
+			withCRC(byte(v.serialNumber>>56), byte(v.serialNumber>>48))...)
+	case bytes.Equal(buf, CMD_READ_ID_1ST_PART):
+		sna := []byte{byte(v.serialNumber >> 56), byte(v.serialNumber >> 48),
+			byte(v.serialNumber >> 40), byte(v.serialNumber >> 32)}
+		var crc byte
+		for _, b := range sna {
+			crc = calcCRC_SI7021(crc, []byte{b})
+			v.response = append(v.response, b, crc)
+		}
+	case bytes.Equal(buf, CMD_READ_ID_2ND_PART):
+		snb := []byte{byte(v.serialNumber >> 24), byte(v.serialNumber >> 16),
+			byte(v.serialNumber >> 8), byte(v.serialNumber)}
+		crc := calcCRC_SI7021(0x0, snb[0:2])
+		v.response = append(v.response, snb[0], snb[1], crc)
+		crc = calcCRC_SI7021(crc, snb[2:4])
+		v.response = append(v.response, snb[2], snb[3], crc)
+	case bytes.Equal(buf, CMD_READ_FIRMWARE_REV):
+		v.response = []byte{byte(v.firmware)}
+	case SensorType(v.serialNumber>>24) == SI_7013_TYPE:
+		return v.processSi7013Command(buf)
+	default:
+		return errors.New(spew.Sprintf("Simulator: unknown command %v", buf))
+	}
+	return nil
+}
+
+// sensirionID return true, when electronic ID
+// is reported in Sensirion format.
+func (v *Simulator) sensirionID() bool {
+	return v.variant != nil && v.variant.IDLayout == ID_LAYOUT_SENSIRION
+}
+
+// processSi7013Command handle commands implemented by Si7013 only.
+func (v *Simulator) processSi7013Command(buf []byte) error {
+	switch {
+	case bytes.Equal(buf, CMD_MEASURE_ANALOG):
+		code := v.analogCode()
+		v.response = withCRC(byte(code>>8), byte(code))
+		v.startConversion(v.userReg2&USER_REG2_NO_HOLD == 0)
+	case bytes.Equal(buf, CMD_READ_USER_REG_2):
+		v.response = []byte{v.userReg2}
+	case len(buf) == 2 && buf[0] == CMD_WRITE_USER_REG_2[0]:
+		v.userReg2 = buf[1]
+	case len(buf) == 2 && buf[0] == CMD_READ_THERMIST_COEF[0]:
+		v.response = []byte{v.thermistCoeffs[buf[1]]}
+	case len(buf) == 3 && buf[0] == CMD_WRITE_THERMIST_COEF[0]:
+		v.thermistCoeffs[buf[1]] = buf[2]
+	default:
+		return errors.New(spew.Sprintf("Simulator: unknown command %v", buf))
+	}
+	return nil
+}
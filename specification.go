@@ -0,0 +1,183 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"math"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Range define interval of values.
+type Range struct {
+	Min float32
+	Max float32
+}
+
+// Contains check, that value is inside interval.
+func (v Range) Contains(value float32) bool {
+	return value >= v.Min && value <= v.Max
+}
+
+// String define stringer interface.
+func (v Range) String() string {
+	return spew.Sprintf("%v..%v", v.Min, v.Max)
+}
+
+// Specification keep datasheet specification of chip.
+// Accuracy values are maximum ones. Accuracy outside
+// of specified band is approximated from datasheet graphs.
+type Specification struct {
+	Chip ChipModel
+	// Relative humidity accuracy (in %RH) inside
+	// RHAccuracyBand and outside of it.
+	RHAccuracy         float32
+	RHAccuracyBand     Range
+	RHAccuracyExtended float32
+	// Temperature accuracy (in *C) inside
+	// TempAccuracyBand and outside of it.
+	TempAccuracy         float32
+	TempAccuracyBand     Range
+	TempAccuracyExtended float32
+	// Operating ranges of humidity (in %RH),
+	// temperature (in *C) and supply voltage (in V).
+	RHRange     Range
+	TempRange   Range
+	SupplyRange Range
+}
+
+// specifications keep datasheet specifications of all known chips.
+var specifications = map[ChipModel]*Specification{
+	CHIP_SI7021: {Chip: CHIP_SI7021,
+		RHAccuracy: 3, RHAccuracyBand: Range{0, 80}, RHAccuracyExtended: 4.5,
+		TempAccuracy: 0.4, TempAccuracyBand: Range{-10, 85}, TempAccuracyExtended: 0.7,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{1.9, 3.6}},
+	CHIP_SI7013: {Chip: CHIP_SI7013,
+		RHAccuracy: 3, RHAccuracyBand: Range{0, 80}, RHAccuracyExtended: 4.5,
+		TempAccuracy: 0.4, TempAccuracyBand: Range{-10, 85}, TempAccuracyExtended: 0.7,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{1.9, 3.6}},
+	CHIP_SI7020: {Chip: CHIP_SI7020,
+		RHAccuracy: 4, RHAccuracyBand: Range{0, 80}, RHAccuracyExtended: 6,
+		TempAccuracy: 0.4, TempAccuracyBand: Range{-10, 85}, TempAccuracyExtended: 0.7,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{1.9, 3.6}},
+	CHIP_SI7006: {Chip: CHIP_SI7006,
+		RHAccuracy: 5, RHAccuracyBand: Range{0, 80}, RHAccuracyExtended: 7,
+		TempAccuracy: 1, TempAccuracyBand: Range{-10, 85}, TempAccuracyExtended: 1.5,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{1.9, 3.6}},
+	CHIP_HTU21D: {Chip: CHIP_HTU21D,
+		RHAccuracy: 3, RHAccuracyBand: Range{20, 80}, RHAccuracyExtended: 4,
+		TempAccuracy: 0.4, TempAccuracyBand: Range{0, 70}, TempAccuracyExtended: 1,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{1.5, 3.6}},
+	CHIP_SHT21: {Chip: CHIP_SHT21,
+		RHAccuracy: 3, RHAccuracyBand: Range{20, 80}, RHAccuracyExtended: 4,
+		TempAccuracy: 0.4, TempAccuracyBand: Range{5, 60}, TempAccuracyExtended: 1,
+		RHRange: Range{0, 100}, TempRange: Range{-40, 125}, SupplyRange: Range{2.1, 3.6}},
+}
+
+// GetSpecification return copy of datasheet specification of
+// chip model, so caller can't change data uncertainty is based on.
+func GetSpecification(model ChipModel) (Specification, error) {
+	spec, ok := specifications[model]
+	if !ok {
+		return Specification{}, &UnsupportedError{Feature: "specification",
+			Reason: spew.Sprintf("no datasheet data for %v", model)}
+	}
+	return *spec, nil
+}
+
+// GetConversionTime return maximum conversion time
+// of relative humidity and temperature for resolution.
+func (v Specification) GetConversionTime(res UserRegFlag) (time.Duration, time.Duration) {
+	cv, err := GetChipVariant(v.Chip)
+	if err != nil {
+		return GetConversionTime(res)
+	}
+	return cv.GetConversionTime(res)
+}
+
+// getResolutionBits return number of bits of
+// relative humidity and temperature codes.
+func getResolutionBits(res UserRegFlag) (uint, uint) {
+	switch res & RES_RH_TEMP_MASK {
+	case RES_RH_8BIT_TEMP_12BIT:
+		return 8, 12
+	case RES_RH_10BIT_TEMP_13BIT:
+		return 10, 13
+	case RES_RH_11BIT_TEMP_11BIT:
+		return 11, 11
+	default:
+		return 12, 14
+	}
+}
+
+// Uncertainty return uncertainty (+/-) of relative humidity (in %RH)
+// and temperature (in *C) measured at resolution res. It combine
+// (root-sum-square) chip accuracy at measured conditions with
+// quantization error of half of least significant bit.
+func (v Specification) Uncertainty(rh, temp float32, res UserRegFlag) (float32, float32) {
+	rhBits, tempBits := getResolutionBits(res)
+	rhLSB := 125 / float64(uint(1)<<rhBits)
+	tempLSB := 175.72 / float64(uint(1)<<tempBits)
+	rhAcc := v.RHAccuracyExtended
+	if v.RHAccuracyBand.Contains(rh) {
+		rhAcc = v.RHAccuracy
+	}
+	tempAcc := v.TempAccuracyExtended
+	if v.TempAccuracyBand.Contains(temp) {
+		tempAcc = v.TempAccuracy
+	}
+	rhU := math.Sqrt(float64(rhAcc)*float64(rhAcc) + rhLSB*rhLSB/4)
+	tempU := math.Sqrt(float64(tempAcc)*float64(tempAcc) + tempLSB*tempLSB/4)
+	return round32(float32(rhU), 3), round32(float32(tempU), 3)
+}
+
+// String define stringer interface.
+func (v Specification) String() string {
+	return spew.Sprintf("%v: RH accuracy +/-%v%%RH (%v%%RH), +/-%v%%RH otherwise; "+
+		"temperature accuracy +/-%v*C (%v*C), +/-%v*C otherwise; "+
+		"operating range %v%%RH, %v*C, supply %vV",
+		v.Chip, v.RHAccuracy, v.RHAccuracyBand, v.RHAccuracyExtended,
+		v.TempAccuracy, v.TempAccuracyBand, v.TempAccuracyExtended,
+		v.RHRange, v.TempRange, v.SupplyRange)
+}
+
+// specModel return chip model, which datasheet apply to sensor:
+// once sensor identity is read, Si70xx chips are taken by sensor
+// type (e.g. Si7020 driven as Si7021 has Si7020 accuracy grade).
+func (v *Si7021) specModel() ChipModel {
+	if v.deviceInfo != nil && v.chip().SensorTypeInID {
+		if model, ok := chipModelBySensorType(v.deviceInfo.SensorType); ok {
+			return model
+		}
+	}
+	return v.chip().Model
+}
+
+// GetSpecification return datasheet specification of
+// chip driver set up for (see SetChipModel, DetectChipModel),
+// or of sensor type, when it was read with ReadDeviceInfo.
+func (v *Si7021) GetSpecification() (Specification, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return GetSpecification(v.specModel())
+}
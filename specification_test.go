@@ -0,0 +1,75 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import "testing"
+
+func TestMeasurementUncertaintyBySensorType(t *testing.T) {
+	tests := []struct {
+		st       SensorType
+		model    ChipModel
+		rhUncert float32
+	}{
+		{SI_7021_TYPE, CHIP_SI7021, 3},
+		{SI_7020_TYPE, CHIP_SI7020, 4},
+		{SI_7006_TYPE, CHIP_SI7006, 5},
+	}
+	for _, test := range tests {
+		sim := NewSimulator()
+		sim.SetSensorType(test.st)
+		sim.SetAmbient(25, 50)
+		sensor := NewSi7021()
+		if _, err := sensor.ReadDeviceInfo(sim); err != nil {
+			t.Fatal(err)
+		}
+		spec, err := sensor.GetSpecification()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec.Chip != test.model {
+			t.Errorf("%v: specification of %v, want %v", test.st, spec.Chip, test.model)
+		}
+		m, err := sensor.ReadMeasurement(sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Quantization error at 12 bit is negligible.
+		if m.RelativeHumidityUncertainty != test.rhUncert {
+			t.Errorf("%v: RH uncertainty %v, want %v", test.st, m.RelativeHumidityUncertainty, test.rhUncert)
+		}
+	}
+}
+
+func TestGetSpecificationCopy(t *testing.T) {
+	spec, err := GetSpecification(CHIP_SI7021)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.RHAccuracy = 99
+	spec2, err := GetSpecification(CHIP_SI7021)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec2.RHAccuracy != 3 {
+		t.Errorf("specification table changed via copy: RH accuracy %v", spec2.RHAccuracy)
+	}
+}
@@ -0,0 +1,197 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TemperatureUnit denote temperature scale.
+type TemperatureUnit int
+
+const (
+	CELSIUS TemperatureUnit = iota
+	FAHRENHEIT
+	KELVIN
+)
+
+// String define stringer interface.
+func (v TemperatureUnit) String() string {
+	switch v {
+	case CELSIUS:
+		return "°C"
+	case FAHRENHEIT:
+		return "°F"
+	case KELVIN:
+		return "K"
+	default:
+		return "<unknown>"
+	}
+}
+
+// code return unit designation used in JSON.
+func (v TemperatureUnit) code() string {
+	switch v {
+	case FAHRENHEIT:
+		return "F"
+	case KELVIN:
+		return "K"
+	default:
+		return "C"
+	}
+}
+
+// Temperature is a temperature quantity (stored in celsius).
+type Temperature float32
+
+// Celsius return temperature in celsius.
+func (v Temperature) Celsius() float32 {
+	return float32(v)
+}
+
+// Fahrenheit return temperature in fahrenheit.
+func (v Temperature) Fahrenheit() float32 {
+	return round32(float32(v)*9/5+32, 2)
+}
+
+// Kelvin return temperature in kelvin.
+func (v Temperature) Kelvin() float32 {
+	return round32(float32(v)+273.15, 2)
+}
+
+// In return temperature in unit.
+func (v Temperature) In(unit TemperatureUnit) float32 {
+	switch unit {
+	case FAHRENHEIT:
+		return v.Fahrenheit()
+	case KELVIN:
+		return v.Kelvin()
+	default:
+		return v.Celsius()
+	}
+}
+
+// Format return temperature in unit as text, for example "77.00°F".
+func (v Temperature) Format(unit TemperatureUnit) string {
+	return spew.Sprintf("%.2f%s", v.In(unit), unit)
+}
+
+// String define stringer interface.
+func (v Temperature) String() string {
+	return v.Format(CELSIUS)
+}
+
+// quantityJSON is JSON representation of physical quantity.
+type quantityJSON struct {
+	Value float32 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// MarshalJSON implement json.Marshaler interface.
+// Temperature is encoded as {"value":25.5,"unit":"C"}.
+func (v Temperature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quantityJSON{Value: v.Celsius(), Unit: CELSIUS.code()})
+}
+
+// UnmarshalJSON implement json.Unmarshaler interface.
+// Values in "C", "F" and "K" units are accepted.
+func (v *Temperature) UnmarshalJSON(data []byte) error {
+	var q quantityJSON
+	err := json.Unmarshal(data, &q)
+	if err != nil {
+		return err
+	}
+	switch q.Unit {
+	case CELSIUS.code():
+		*v = Temperature(q.Value)
+	case FAHRENHEIT.code():
+		*v = Temperature(round32((q.Value-32)*5/9, 2))
+	case KELVIN.code():
+		*v = Temperature(round32(q.Value-273.15, 2))
+	default:
+		return errors.New(spew.Sprintf("Unknown temperature unit %q", q.Unit))
+	}
+	return nil
+}
+
+// RELATIVE_HUMIDITY_UNIT is relative humidity unit designation.
+const RELATIVE_HUMIDITY_UNIT = "%RH"
+
+// RelativeHumidity is a relative humidity quantity (stored in percents).
+type RelativeHumidity float32
+
+// Percent return relative humidity in percents.
+func (v RelativeHumidity) Percent() float32 {
+	return float32(v)
+}
+
+// Fraction return relative humidity as fraction of 1.
+func (v RelativeHumidity) Fraction() float32 {
+	return float32(v) / 100
+}
+
+// String define stringer interface.
+func (v RelativeHumidity) String() string {
+	return spew.Sprintf("%.2f%s", v.Percent(), RELATIVE_HUMIDITY_UNIT)
+}
+
+// MarshalJSON implement json.Marshaler interface.
+// Relative humidity is encoded as {"value":50,"unit":"%RH"}.
+func (v RelativeHumidity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quantityJSON{Value: v.Percent(), Unit: RELATIVE_HUMIDITY_UNIT})
+}
+
+// UnmarshalJSON implement json.Unmarshaler interface.
+func (v *RelativeHumidity) UnmarshalJSON(data []byte) error {
+	var q quantityJSON
+	err := json.Unmarshal(data, &q)
+	if err != nil {
+		return err
+	}
+	if q.Unit != RELATIVE_HUMIDITY_UNIT {
+		return errors.New(spew.Sprintf("Unknown relative humidity unit %q", q.Unit))
+	}
+	*v = RelativeHumidity(q.Value)
+	return nil
+}
+
+// ReadRelativeHumidityAndTemperatureTyped return relative
+// humidity and temperature as unit-aware quantities.
+func (v *Si7021) ReadRelativeHumidityAndTemperatureTyped(bus Bus) (RelativeHumidity, Temperature, error) {
+	return v.ReadRelativeHumidityAndTemperatureTypedCtx(context.Background(), bus)
+}
+
+// ReadRelativeHumidityAndTemperatureTypedCtx is the same as ReadRelativeHumidityAndTemperatureTyped, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadRelativeHumidityAndTemperatureTypedCtx(ctx context.Context,
+	bus Bus) (RelativeHumidity, Temperature, error) {
+
+	rh, temp, err := v.ReadRelativeHumidityAndTemperatureCtx(ctx, bus)
+	if err != nil {
+		return 0, 0, err
+	}
+	return RelativeHumidity(rh), Temperature(temp), nil
+}
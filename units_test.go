@@ -0,0 +1,121 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemperatureUnits(t *testing.T) {
+	tests := []struct {
+		celsius    Temperature
+		fahrenheit float32
+		kelvin     float32
+		text       string
+	}{
+		{-40, -40, 233.15, "-40.00°C"},
+		{0, 32, 273.15, "0.00°C"},
+		{25, 77, 298.15, "25.00°C"},
+		{100, 212, 373.15, "100.00°C"},
+	}
+	for _, test := range tests {
+		if f := test.celsius.Fahrenheit(); f != test.fahrenheit || test.celsius.In(FAHRENHEIT) != f {
+			t.Errorf("%v in fahrenheit = %v, want %v", test.celsius, f, test.fahrenheit)
+		}
+		if k := test.celsius.Kelvin(); k != test.kelvin || test.celsius.In(KELVIN) != k {
+			t.Errorf("%v in kelvin = %v, want %v", test.celsius, k, test.kelvin)
+		}
+		if s := test.celsius.String(); s != test.text {
+			t.Errorf("%v formatted as %q, want %q", float32(test.celsius), s, test.text)
+		}
+	}
+	if s := Temperature(25).Format(FAHRENHEIT); s != "77.00°F" {
+		t.Errorf("25*C formatted in fahrenheit as %q", s)
+	}
+}
+
+func TestTemperatureJSON(t *testing.T) {
+	data, err := json.Marshal(Temperature(25.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"value":25.5,"unit":"C"}` {
+		t.Errorf("temperature marshalled as %s", data)
+	}
+	var temp Temperature
+	if err := json.Unmarshal(data, &temp); err != nil || temp != 25.5 {
+		t.Errorf("round-trip give %v, %v", temp, err)
+	}
+	tests := []struct {
+		data string
+		temp Temperature
+	}{
+		{`{"value":77,"unit":"F"}`, 25},
+		{`{"value":298.15,"unit":"K"}`, 25},
+		{`{"value":-40,"unit":"F"}`, -40},
+	}
+	for _, test := range tests {
+		if err := json.Unmarshal([]byte(test.data), &temp); err != nil || temp != test.temp {
+			t.Errorf("%s unmarshalled as %v, %v, want %v", test.data, temp, err, test.temp)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"value":25,"unit":"R"}`), &temp); err == nil {
+		t.Error("expected error for unknown temperature unit")
+	}
+}
+
+func TestRelativeHumidityJSON(t *testing.T) {
+	rh := RelativeHumidity(45.5)
+	if rh.Fraction() != 0.455 || rh.String() != "45.50%RH" {
+		t.Errorf("unexpected fraction %v or text %q", rh.Fraction(), rh.String())
+	}
+	data, err := json.Marshal(rh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"value":45.5,"unit":"%RH"}` {
+		t.Errorf("relative humidity marshalled as %s", data)
+	}
+	var rh2 RelativeHumidity
+	if err := json.Unmarshal(data, &rh2); err != nil || rh2 != rh {
+		t.Errorf("round-trip give %v, %v", rh2, err)
+	}
+	if err := json.Unmarshal([]byte(`{"value":45.5,"unit":"%"}`), &rh2); err == nil {
+		t.Error("expected error for unknown relative humidity unit")
+	}
+}
+
+func TestMeasurementJSONRoundTrip(t *testing.T) {
+	m := Measurement{RelativeHumidity: 41.18, Temperature: 23.5}
+	data, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 Measurement
+	if err := json.Unmarshal(data, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if m2.RelativeHumidity != m.RelativeHumidity || m2.Temperature != m.Temperature {
+		t.Errorf("round-trip give %v, %v", m2.RelativeHumidity, m2.Temperature)
+	}
+}
@@ -0,0 +1,132 @@
+//--------------------------------------------------------------------------------------------------
+//
+// Copyright (c) 2018 Denis Dyakov
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+// associated documentation files (the "Software"), to deal in the Software without restriction,
+// including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial
+// portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+// BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+//--------------------------------------------------------------------------------------------------
+
+package si7021
+
+import (
+	"context"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// USER_REG_RESERVED_MASK select reserved bits
+// of user register 1 (D5, D4, D3 and D1), which
+// must be kept unchanged on write.
+const USER_REG_RESERVED_MASK byte = 0x3A
+
+// UserRegister is decoded content of RH/T user register 1.
+type UserRegister struct {
+	// Measure resolution, one of RES_RH_* values.
+	Resolution UserRegFlag
+	// Heater enabled (HTRE bit).
+	HeaterEnabled bool
+	// Power supply voltage is lower than 1.9V
+	// (VDDS bit). Read only, ignored on write.
+	VoltageLow bool
+	// Reserved bits as read from sensor.
+	Reserved byte
+}
+
+// DecodeUserRegister decode raw user register value.
+func DecodeUserRegister(reg byte) *UserRegister {
+	v := &UserRegister{
+		Resolution:    UserRegFlag(reg) & RES_RH_TEMP_MASK,
+		HeaterEnabled: UserRegFlag(reg)&HEATER_ENABLED != 0,
+		VoltageLow:    UserRegFlag(reg)&VOLTAGE_LOW != 0,
+		Reserved:      reg & USER_REG_RESERVED_MASK,
+	}
+	return v
+}
+
+// Encode return raw user register value to write to sensor.
+// Read only VDDS bit is not encoded.
+func (v *UserRegister) Encode() byte {
+	reg := byte(v.Resolution&RES_RH_TEMP_MASK) | v.Reserved&USER_REG_RESERVED_MASK
+	if v.HeaterEnabled {
+		reg |= byte(HEATER_ENABLED)
+	}
+	return reg
+}
+
+// String define stringer interface.
+func (v *UserRegister) String() string {
+	return spew.Sprintf("Resolution: %v, Heater enabled: %v, Voltage low: %v, Reserved: 0x%02X",
+		v.Resolution, v.HeaterEnabled, v.VoltageLow, v.Reserved)
+}
+
+// ReadUserRegister read and decode user register 1.
+func (v *Si7021) ReadUserRegister(bus Bus) (*UserRegister, error) {
+	return v.ReadUserRegisterCtx(context.Background(), bus)
+}
+
+// ReadUserRegisterCtx is the same as ReadUserRegister, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) ReadUserRegisterCtx(ctx context.Context, bus Bus) (*UserRegister, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	ur, err := v.readUserReg(ctx, bus)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeUserRegister(ur), nil
+}
+
+// UpdateUserRegister make read-modify-write of user register 1
+// in one transaction: read register, pass decoded content to
+// update function, write it back keeping reserved bits intact
+// and verify with read-back, that sensor accepted new value.
+func (v *Si7021) UpdateUserRegister(bus Bus, update func(ur *UserRegister)) error {
+	return v.UpdateUserRegisterCtx(context.Background(), bus, update)
+}
+
+// UpdateUserRegisterCtx is the same as UpdateUserRegister, but
+// abort bus operations and waits, when ctx is done.
+func (v *Si7021) UpdateUserRegisterCtx(ctx context.Context, bus Bus, update func(ur *UserRegister)) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	lg.Debug("Updating user register...")
+	return v.updateUserRegister(ctx, bus, update)
+}
+
+func (v *Si7021) updateUserRegister(ctx context.Context, bus Bus, update func(ur *UserRegister)) error {
+	reg, err := v.readUserReg(ctx, bus)
+	if err != nil {
+		return err
+	}
+	ur := DecodeUserRegister(reg)
+	update(ur)
+	// Never touch reserved bits.
+	ur.Reserved = reg & USER_REG_RESERVED_MASK
+	reg = ur.Encode()
+	err = writeBytes(ctx, bus, append(CMD_WRITE_USER_REG_1, reg))
+	if err != nil {
+		return err
+	}
+	reg2, err := v.readUserReg(ctx, bus)
+	if err != nil {
+		return err
+	}
+	if reg2&^byte(VOLTAGE_LOW) != reg {
+		return &VerifyError{Register: "user register 1",
+			Written: reg, ReadBack: reg2 &^ byte(VOLTAGE_LOW)}
+	}
+	return nil
+}
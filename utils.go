@@ -22,11 +22,9 @@
 package si7021
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"math"
-
-	i2c "github.com/d2r2/go-i2c"
+	"time"
 )
 
 // Utility functions
@@ -108,18 +106,18 @@ func round32(value float32, precision int) float32 {
 	return float32(round64(float64(value), precision))
 }
 
-// Read byte block from i2c device to struct object.
-func readDataToStruct(i2c *i2c.I2C, byteCount int,
-	byteOrder binary.ByteOrder, obj interface{}) error {
-	buf1 := make([]byte, byteCount)
-	_, err := i2c.ReadBytes(buf1)
-	if err != nil {
-		return err
+// sleepContext pause current goroutine for duration d,
+// or less, if context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
 	}
-	buf := bytes.NewBuffer(buf1)
-	err = binary.Read(buf, byteOrder, obj)
-	if err != nil {
-		return err
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return nil
 }